@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"go_final_project/backup"
+	"go_final_project/calendar"
+	"go_final_project/config"
+	"go_final_project/db"
+)
+
+func openDB(fs *flag.FlagSet, args []string) (*db.DB, config.Config, error) {
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return nil, cfg, err
+	}
+	database, err := db.Open(cfg.Database(), cfg.DBOptions())
+	if err != nil {
+		return nil, cfg, err
+	}
+	return database, cfg, nil
+}
+
+func migrateCmd(args []string) error {
+	database, _, err := openDB(flag.NewFlagSet("migrate", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if err := db.Migrate(database); err != nil {
+		return err
+	}
+	version, err := db.Version(database)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Версия схемы:", version)
+	return nil
+}
+
+func backupCmd(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := fs.String("o", "", "файл резервной копии (по умолчанию снимок в backup_dir, его найдёт restore -latest)")
+	database, cfg, err := openDB(fs, args)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if *out == "" {
+		manager := &backup.Manager{DB: database, Dir: cfg.BackupDir, Keep: cfg.BackupKeep, MaxAge: cfg.BackupMaxAge}
+		snapshot, err := manager.Snapshot()
+		if err != nil {
+			return err
+		}
+		*out = snapshot.Path
+	} else if err := db.Backup(database, *out); err != nil {
+		return err
+	}
+	fmt.Println("Резервная копия сохранена в", *out)
+	return nil
+}
+
+func restoreCmd(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	latest := fs.Bool("latest", false, "взять самую свежую копию из директории резервных копий")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var src string
+	switch {
+	case *latest && fs.NArg() == 0:
+		snapshot, err := backup.Latest(cfg.BackupDir)
+		if err != nil {
+			return err
+		}
+		src = snapshot.Path
+	case !*latest && fs.NArg() == 1:
+		src = fs.Arg(0)
+	default:
+		return errors.New("нужно указать файл резервной копии или -latest")
+	}
+
+	if err := db.Restore(src, cfg.Database()); err != nil {
+		return err
+	}
+	fmt.Println("База данных восстановлена из", src)
+	return nil
+}
+
+func vacuumCmd(args []string) error {
+	database, _, err := openDB(flag.NewFlagSet("vacuum", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if err := db.Vacuum(database); err != nil {
+		return err
+	}
+	fmt.Println("VACUUM выполнен")
+	return nil
+}
+
+func checkCmd(args []string) error {
+	database, _, err := openDB(flag.NewFlagSet("check", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if err := db.IntegrityCheck(database); err != nil {
+		return err
+	}
+	if err := db.CheckSchema(database); err != nil {
+		return err
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+func exportCmd(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "json", "формат выгрузки: json или csv")
+	out := fs.String("o", "", "файл для выгрузки (по умолчанию stdout)")
+	database, _, err := openDB(fs, args)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	// Старая схема не знает новых колонок, поэтому выгрузка сначала её обновляет.
+	if err := db.Migrate(database); err != nil {
+		return err
+	}
+	tasks, err := db.AllTasks(database)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string][]db.Task{"tasks": tasks})
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "date", "title", "comment", "repeat", "priority"})
+		for _, t := range tasks {
+			cw.Write([]string{strconv.FormatInt(t.ID, 10), t.Date, t.Title, t.Comment, t.Repeat, t.Priority})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("неизвестный формат %q", *format)
+	}
+}
+
+func holidaysCmd(args []string) error {
+	fs := flag.NewFlagSet("holidays", flag.ExitOnError)
+	replace := fs.Bool("replace", false, "удалить дни тех же лет, которых нет в файле")
+	database, _, err := openDB(fs, args)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if fs.NArg() != 1 {
+		return errors.New("нужно указать файл календаря")
+	}
+	// Календарь можно загрузить до первого запуска сервера.
+	if err := db.Migrate(database); err != nil {
+		return err
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	days, err := calendar.Read(f)
+	if err != nil {
+		return err
+	}
+	if err := calendar.Import(database, days, *replace); err != nil {
+		return err
+	}
+	fmt.Println("Загружено дней календаря:", len(days), "- работающий сервер подхватит их в течение минуты")
+	return nil
+}
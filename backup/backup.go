@@ -0,0 +1,142 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go_final_project/db"
+)
+
+const (
+	prefix     = "scheduler-"
+	suffix     = ".db"
+	timeLayout = "20060102-150405.000"
+)
+
+// Snapshot - снимок в директории копий. Наружу (в /api/admin/backup)
+// отдаётся только имя файла, путь на сервере остаётся внутри.
+type Snapshot struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"-"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+}
+
+// Manager делает снимки работающей базы через VACUUM INTO и удаляет
+// старые по правилам хранения: не больше Keep штук и не старше MaxAge.
+type Manager struct {
+	DB     *db.DB
+	Dir    string
+	Keep   int
+	MaxAge time.Duration
+
+	mu sync.Mutex
+}
+
+func (m *Manager) Snapshot() (Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return Snapshot{}, err
+	}
+	now := time.Now().Truncate(time.Millisecond)
+	name := prefix + now.Format(timeLayout) + suffix
+	path := filepath.Join(m.Dir, name)
+	if err := db.Backup(m.DB, path); err != nil {
+		return Snapshot{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if err := m.prune(now); err != nil {
+		log.Println("Ошибка при удалении старых резервных копий: ", err)
+	}
+	return Snapshot{Name: name, Path: path, Created: now, Size: info.Size()}, nil
+}
+
+func (m *Manager) prune(now time.Time) error {
+	snapshots, err := List(m.Dir)
+	if err != nil {
+		return err
+	}
+	for i, s := range snapshots {
+		expired := m.MaxAge > 0 && now.Sub(s.Created) > m.MaxAge
+		extra := m.Keep > 0 && i >= m.Keep
+		if expired || extra {
+			if err := os.Remove(s.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s, err := m.Snapshot()
+			if err != nil {
+				log.Println("Ошибка при резервном копировании: ", err)
+				continue
+			}
+			log.Println("Резервная копия сохранена в", s.Path)
+		}
+	}
+}
+
+// List возвращает снимки из dir, начиная с самого нового.
+func List(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		created, err := time.ParseInLocation(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{Name: name, Path: filepath.Join(dir, name), Created: created, Size: info.Size()})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.After(snapshots[j].Created)
+	})
+	return snapshots, nil
+}
+
+func Latest(dir string) (Snapshot, error) {
+	snapshots, err := List(dir)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if len(snapshots) == 0 {
+		return Snapshot{}, fmt.Errorf("в %s нет резервных копий", dir)
+	}
+	return snapshots[0], nil
+}
@@ -0,0 +1,281 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_final_project/handlers"
+)
+
+const help = `Команды:
+/add НАЗВАНИЕ [ГГГГММДД] [ПРАВИЛО] - добавить задачу, например /add Поплавать 20240301 d 7
+/today - задачи на сегодня
+/done N - отметить задачу N выполненной`
+
+var dateToken = regexp.MustCompile(`^\d{8}$`)
+
+// Bot выполняет команды из чата через HTTP API задач. API - обработчик
+// сервера (в serve это его mux), поэтому проверки и журнал изменений
+// те же, что у веб-интерфейса.
+type Bot struct {
+	Transport Transport
+	API       http.Handler
+	// AllowedChats - чаты, из которых принимаются команды; пустой список
+	// не разрешает ни одного.
+	AllowedChats []int64
+	// Now подменяется в тестах; по умолчанию time.Now.
+	Now func() time.Time
+}
+
+func (b *Bot) now() time.Time {
+	if b.Now != nil {
+		return b.Now()
+	}
+	return time.Now()
+}
+
+func (b *Bot) allowed(chatID int64) bool {
+	for _, id := range b.AllowedChats {
+		if id == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+// Run получает сообщения, пока не отменён ctx. Ошибки транспорта не
+// останавливают бота: он повторяет запрос через несколько секунд.
+func (b *Bot) Run(ctx context.Context) {
+	if len(b.AllowedChats) == 0 {
+		log.Println("Бот: список разрешённых чатов (bot_chats) пуст, команды не будут приниматься ни из одного чата")
+	}
+	var offset int64
+	for ctx.Err() == nil {
+		messages, err := b.Transport.Updates(ctx, offset)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Println("Ошибка при получении сообщений бота: ", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		for _, m := range messages {
+			offset = m.UpdateID + 1
+			b.Handle(ctx, m)
+		}
+	}
+}
+
+// Handle отвечает на одно сообщение.
+func (b *Bot) Handle(ctx context.Context, m Message) {
+	if m.Text == "" {
+		return
+	}
+	if !b.allowed(m.ChatID) {
+		log.Printf("Бот: сообщение из чата %d не принято, чата нет в списке разрешённых", m.ChatID)
+		return
+	}
+	if err := b.Transport.Send(ctx, m.ChatID, b.Reply(ctx, m)); err != nil {
+		log.Println("Ошибка при отправке ответа бота: ", err)
+	}
+}
+
+// Reply выполняет команду и возвращает текст ответа.
+func (b *Bot) Reply(ctx context.Context, m Message) string {
+	fields := strings.Fields(m.Text)
+	if len(fields) == 0 {
+		return help
+	}
+	// В группах команда приходит как /add@имя_бота.
+	cmd, _, _ := strings.Cut(fields[0], "@")
+	args := fields[1:]
+
+	switch cmd {
+	case "/start", "/help":
+		return help
+	case "/add":
+		return b.add(ctx, m, args)
+	case "/today":
+		return b.today(ctx, m)
+	case "/done":
+		return b.done(ctx, m, args)
+	default:
+		return "Неизвестная команда.\n\n" + help
+	}
+}
+
+// parseAdd разбирает аргументы /add: название, затем необязательные дата
+// ГГГГММДД и правило повторения. Без даты правилом считается самый длинный
+// конец сообщения, который разбирается как правило (d 7, bd 1, mw 2:2, m 1 >).
+func parseAdd(args []string) (title, date, repeat string) {
+	split := len(args)
+	for i, arg := range args {
+		if i == 0 {
+			continue
+		}
+		if dateToken.MatchString(arg) {
+			date = arg
+			split = i
+			repeat = strings.Join(args[i+1:], " ")
+			break
+		}
+		if rule := strings.Join(args[i:], " "); handlers.ValidRepeat(rule) == nil {
+			split = i
+			repeat = rule
+			break
+		}
+	}
+	title = strings.Join(args[:split], " ")
+	return title, date, repeat
+}
+
+func (b *Bot) add(ctx context.Context, m Message, args []string) string {
+	title, date, repeat := parseAdd(args)
+	if title == "" {
+		return "Укажите название задачи: /add Поплавать 20240301 d 7"
+	}
+	var created struct {
+		ID   json.Number `json:"id"`
+		Date string      `json:"date"`
+	}
+	err := b.call(ctx, m, http.MethodPost, "/api/task", map[string]string{
+		"title":  title,
+		"date":   date,
+		"repeat": repeat,
+	}, &created)
+	if err != nil {
+		return "Не удалось добавить задачу: " + err.Error()
+	}
+	reply := fmt.Sprintf("Добавлена задача %s: %s на %s", created.ID, title, formatDate(created.Date))
+	if repeat != "" {
+		reply += ", повтор " + repeat
+	}
+	return reply
+}
+
+func (b *Bot) today(ctx context.Context, m Message) string {
+	var list struct {
+		Tasks []struct {
+			ID     string `json:"id"`
+			Date   string `json:"date"`
+			Title  string `json:"title"`
+			Repeat string `json:"repeat"`
+		} `json:"tasks"`
+	}
+	if err := b.call(ctx, m, http.MethodGet, "/api/tasks", nil, &list); err != nil {
+		return "Не удалось получить задачи: " + err.Error()
+	}
+
+	today := b.now().Format("20060102")
+	var lines []string
+	for _, t := range list.Tasks {
+		if t.Date != today {
+			continue
+		}
+		line := t.ID + ". " + t.Title
+		if t.Repeat != "" {
+			line += " (" + t.Repeat + ")"
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return "На сегодня задач нет"
+	}
+	return "На сегодня:\n" + strings.Join(lines, "\n")
+}
+
+func (b *Bot) done(ctx context.Context, m Message, args []string) string {
+	if len(args) != 1 {
+		return "Укажите номер задачи: /done 12"
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id <= 0 {
+		return "Некорректный номер задачи: " + args[0]
+	}
+	if err := b.call(ctx, m, http.MethodPost, "/api/task/done?id="+strconv.FormatInt(id, 10), nil, nil); err != nil {
+		return "Не удалось отметить задачу: " + err.Error()
+	}
+	return fmt.Sprintf("Задача %d выполнена", id)
+}
+
+func formatDate(date string) string {
+	t, err := time.Parse("20060102", date)
+	if err != nil {
+		return date
+	}
+	return t.Format("02.01.2006")
+}
+
+// recorder принимает ответ API, вызванного внутри процесса.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(p)
+}
+
+// call выполняет запрос к API от имени автора сообщения. Ошибка содержит
+// текст из поля error ответа.
+func (b *Bot) call(ctx context.Context, m Message, method, target string, body, result interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(handlers.WithActor(ctx, "telegram:"+m.From), method, target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "127.0.0.1:0"
+
+	rec := &recorder{header: http.Header{}}
+	b.API.ServeHTTP(rec, req)
+
+	var resp struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(rec.body.Bytes(), &resp)
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	if rec.status >= http.StatusBadRequest {
+		return errors.New(http.StatusText(rec.status))
+	}
+	if result != nil {
+		return json.Unmarshal(rec.body.Bytes(), result)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultTelegramURL = "https://api.telegram.org"
+
+// Telegram работает с Bot API через long polling (getUpdates).
+type Telegram struct {
+	// BaseURL - адрес Bot API; по умолчанию DefaultTelegramURL.
+	BaseURL string
+	Token   string
+	// PollTimeout - сколько сервер держит запрос getUpdates без новых сообщений.
+	PollTimeout time.Duration
+	Client      *http.Client
+}
+
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		From struct {
+			ID       int64  `json:"id"`
+			Username string `json:"username"`
+		} `json:"from"`
+	} `json:"message"`
+}
+
+func (t *Telegram) call(ctx context.Context, method string, body interface{}, result interface{}) error {
+	base := t.BaseURL
+	if base == "" {
+		base = DefaultTelegramURL
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	u := strings.TrimRight(base, "/") + "/bot" + t.Token + "/" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := t.Client
+	if client == nil {
+		client = &http.Client{Timeout: t.PollTimeout + 10*time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// Токен входит в адрес запроса, не выводим его в журнал.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("ошибка запроса %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var r telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("%s: неверный ответ (%s): %w", method, resp.Status, err)
+	}
+	if !r.OK {
+		return fmt.Errorf("%s: %s", method, r.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Result, result)
+}
+
+func (t *Telegram) Updates(ctx context.Context, offset int64) ([]Message, error) {
+	var updates []telegramUpdate
+	err := t.call(ctx, "getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(t.PollTimeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, len(updates))
+	for _, u := range updates {
+		m := Message{UpdateID: u.UpdateID}
+		if u.Message != nil {
+			m.ChatID = u.Message.Chat.ID
+			m.Text = u.Message.Text
+			m.From = u.Message.From.Username
+			if m.From == "" {
+				m.From = strconv.FormatInt(u.Message.From.ID, 10)
+			}
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+func (t *Telegram) Send(ctx context.Context, chatID int64, text string) error {
+	return t.call(ctx, "sendMessage", map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+	}, nil)
+}
@@ -0,0 +1,21 @@
+package bot
+
+import "context"
+
+// Message - входящее сообщение из чата.
+type Message struct {
+	UpdateID int64
+	ChatID   int64
+	From     string
+	Text     string
+}
+
+// Transport связывает бота с мессенджером: получает новые сообщения и
+// отправляет ответы. Реальная реализация - Telegram; в тестах его
+// заменяет локальный HTTP-сервер с тем же API.
+type Transport interface {
+	// Updates ждёт сообщения с UpdateID >= offset. Пустой ответ без
+	// ошибки означает, что новых сообщений пока нет.
+	Updates(ctx context.Context, offset int64) ([]Message, error)
+	Send(ctx context.Context, chatID int64, text string) error
+}
@@ -0,0 +1,193 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"go_final_project/db"
+)
+
+const dateFormat = "20060102"
+
+// Виды дней в календаре: праздник или выходной, перенесённый на рабочий.
+const (
+	Holiday = "holiday"
+	Workday = "workday"
+)
+
+type Day struct {
+	Date  string `json:"date"`
+	Kind  string `json:"kind"`
+	Title string `json:"title,omitempty"`
+}
+
+// Validate проверяет дату и вид дня; пустой вид - праздник.
+func (d *Day) Validate() error {
+	if _, err := time.Parse(dateFormat, d.Date); err != nil {
+		return fmt.Errorf("неверная дата %s", d.Date)
+	}
+	if d.Kind == "" {
+		d.Kind = Holiday
+	}
+	if d.Kind != Holiday && d.Kind != Workday {
+		return fmt.Errorf("неизвестный вид дня %s, ожидается holiday или workday", d.Kind)
+	}
+	return nil
+}
+
+// Calendar - копия таблицы holidays в памяти: правила повторения
+// проверяют рабочие дни без запросов к базе.
+type Calendar struct {
+	mu   sync.RWMutex
+	days map[string]string
+}
+
+// Default - календарь сервера; serve загружает его при запуске и
+// перечитывает каждые ReloadInterval, а обработчики /api/holidays -
+// сразу после каждого изменения.
+var Default = &Calendar{}
+
+// ReloadInterval - как быстро сервер замечает календарь, загруженный
+// командой holidays в обход API.
+const ReloadInterval = time.Minute
+
+// Working сообщает, рабочий ли день: понедельник-пятница, кроме праздников,
+// и перенесённые на выходные рабочие дни.
+func (c *Calendar) Working(t time.Time) bool {
+	c.mu.RLock()
+	kind := c.days[t.Format(dateFormat)]
+	c.mu.RUnlock()
+	switch kind {
+	case Holiday:
+		return false
+	case Workday:
+		return true
+	}
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// Set заменяет дни календаря.
+func (c *Calendar) Set(days []Day) {
+	m := make(map[string]string, len(days))
+	for _, d := range days {
+		m[d.Date] = d.Kind
+	}
+	c.mu.Lock()
+	c.days = m
+	c.mu.Unlock()
+}
+
+// Load перечитывает календарь из базы.
+func (c *Calendar) Load(q db.Queryer) error {
+	days, err := List(q, "", "")
+	if err != nil {
+		return err
+	}
+	c.Set(days)
+	return nil
+}
+
+// Run перечитывает календарь каждые interval, пока не отменён ctx.
+func (c *Calendar) Run(ctx context.Context, q db.Queryer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := c.Load(q); err != nil && ctx.Err() == nil {
+			log.Println("Ошибка при загрузке производственного календаря: ", err)
+		}
+	}
+}
+
+// List возвращает дни календаря с from по to включительно; пустая граница
+// не ограничивает.
+func List(q db.Queryer, from, to string) ([]Day, error) {
+	if to == "" {
+		to = "99999999"
+	}
+	rows, err := q.Query(`SELECT date, kind, title FROM holidays WHERE date >= ? AND date <= ? ORDER BY date`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении календаря: %w", err)
+	}
+	defer rows.Close()
+
+	days := []Day{}
+	for rows.Next() {
+		var d Day
+		if err := rows.Scan(&d.Date, &d.Kind, &d.Title); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении календаря: %w", err)
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// Save добавляет дни или заменяет уже записанные на те же даты.
+func Save(q db.Queryer, days []Day) error {
+	for _, d := range days {
+		_, err := q.Exec(`INSERT INTO holidays (date, kind, title) VALUES (?, ?, ?)
+			ON CONFLICT (date) DO UPDATE SET kind = excluded.kind, title = excluded.title`,
+			d.Date, d.Kind, d.Title)
+		if err != nil {
+			return fmt.Errorf("ошибка при записи дня %s: %w", d.Date, err)
+		}
+	}
+	return nil
+}
+
+// Delete удаляет дни с from по to включительно и возвращает их количество.
+func Delete(q db.Queryer, from, to string) (int64, error) {
+	res, err := q.Exec(`DELETE FROM holidays WHERE date >= ? AND date <= ?`, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при удалении дней календаря: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Import записывает дни одной транзакцией. С replace дни тех же лет,
+// которых нет в days, удаляются - так календарь на год заменяется целиком.
+func Import(database *db.DB, days []Day, replace bool) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка при открытии транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	if replace {
+		years := map[string]bool{}
+		for _, d := range days {
+			years[d.Date[:4]] = true
+		}
+		for year := range years {
+			if _, err := Delete(tx, year+"0101", year+"1231"); err != nil {
+				return err
+			}
+		}
+	}
+	if err := Save(tx, days); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sortDays упорядочивает дни по дате; при повторе даты остаётся последний.
+func sortDays(days []Day) []Day {
+	byDate := make(map[string]Day, len(days))
+	for _, d := range days {
+		byDate[d.Date] = d
+	}
+	sorted := make([]Day, 0, len(byDate))
+	for _, d := range byDate {
+		sorted = append(sorted, d)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date < sorted[j].Date })
+	return sorted
+}
@@ -0,0 +1,104 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xmlCalendar - формат производственного календаря xmlcalendar.ru:
+// d - месяц и день "ММ.ДД", t - 1 выходной, 2 сокращённый рабочий,
+// 3 рабочий (перенесённый выходной), h - номер праздника.
+type xmlCalendar struct {
+	Year     string `xml:"year,attr"`
+	Holidays []struct {
+		ID    string `xml:"id,attr"`
+		Title string `xml:"title,attr"`
+	} `xml:"holidays>holiday"`
+	Days []struct {
+		D string `xml:"d,attr"`
+		T string `xml:"t,attr"`
+		H string `xml:"h,attr"`
+	} `xml:"days>day"`
+}
+
+// Read разбирает файл календаря: XML в формате xmlcalendar.ru (например,
+// производственный календарь России) или текст, где в каждой строке
+// "ГГГГММДД [holiday|workday] [название]", а строки с # - комментарии.
+func Read(r io.Reader) ([]Day, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var days []Day
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '<' {
+		days, err = readXML(trimmed)
+	} else {
+		days, err = readText(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range days {
+		if err := days[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return sortDays(days), nil
+}
+
+func readXML(data []byte) ([]Day, error) {
+	var c xmlCalendar
+	if err := xml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("неверный XML календаря: %w", err)
+	}
+	if len(c.Year) != 4 {
+		return nil, fmt.Errorf("в календаре не указан год")
+	}
+	titles := make(map[string]string, len(c.Holidays))
+	for _, h := range c.Holidays {
+		titles[h.ID] = h.Title
+	}
+
+	var days []Day
+	for _, d := range c.Days {
+		month, day, ok := strings.Cut(d.D, ".")
+		if !ok {
+			return nil, fmt.Errorf("неверная дата %s в календаре", d.D)
+		}
+		date := c.Year + month + day
+		switch d.T {
+		case "1":
+			days = append(days, Day{Date: date, Kind: Holiday, Title: titles[d.H]})
+		case "3":
+			days = append(days, Day{Date: date, Kind: Workday})
+		}
+		// Сокращённые дни (t=2) остаются рабочими.
+	}
+	return days, nil
+}
+
+func readText(data []byte) ([]Day, error) {
+	var days []Day
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		d := Day{Date: fields[0]}
+		if len(fields) > 1 {
+			d.Kind = fields[1]
+			d.Title = strings.Join(fields[2:], " ")
+		}
+		if err := d.Validate(); err != nil {
+			return nil, fmt.Errorf("строка %d: %w", n, err)
+		}
+		days = append(days, d)
+	}
+	return days, scanner.Err()
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Task struct {
+	ID       string `json:"id"`
+	Date     string `json:"date"`
+	Title    string `json:"title"`
+	Comment  string `json:"comment"`
+	Repeat   string `json:"repeat"`
+	Priority string `json:"priority,omitempty"`
+	// Без omitempty: nil (null) оставляет теги и зависимости как есть,
+	// пустой список их снимает.
+	Tags      []string `json:"tags"`
+	BlockedBy []string `json:"blocked_by"`
+	Version   string   `json:"version,omitempty"`
+}
+
+type client struct {
+	server string
+	token  string
+	actor  string
+	http   *http.Client
+}
+
+func newClient(server, token string) *client {
+	c := &client{
+		server: strings.TrimRight(server, "/"),
+		token:  token,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+	// Имя пользователя попадает в журнал изменений на сервере.
+	if u, err := user.Current(); err == nil {
+		c.actor = u.Username
+	}
+	return c
+}
+
+func (c *client) do(method, path string, query url.Values, body any) ([]byte, error) {
+	return c.doIfMatch(method, path, query, body, "")
+}
+
+// doIfMatch отправляет запрос с заголовком If-Match, если version не пустая:
+// сервер отклонит изменение, если задачу успели поменять.
+func (c *client) doIfMatch(method, path string, query url.Values, body any, version string) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	u := c.server + "/" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if version != "" {
+		req.Header.Set("If-Match", `"`+version+`"`)
+	}
+	if c.actor != "" {
+		req.Header.Set("X-Actor", c.actor)
+	}
+	if c.token != "" {
+		req.AddCookie(&http.Cookie{Name: "token", Value: c.token})
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(bytes.NewReader(data)).Decode(&apiErr) == nil && apiErr.Error != "" {
+		return nil, errors.New(apiErr.Error)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+func (c *client) tasks(search, sort, order string, tags []string) ([]Task, []byte, error) {
+	query := url.Values{}
+	if search != "" {
+		query.Set("search", search)
+	}
+	if sort != "" {
+		query.Set("sort", sort)
+	}
+	if order != "" {
+		query.Set("order", order)
+	}
+	for _, tag := range tags {
+		query.Add("tag", tag)
+	}
+	data, err := c.do(http.MethodGet, "api/tasks", query, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var resp struct {
+		Tasks []Task `json:"tasks"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, nil, err
+	}
+	return resp.Tasks, data, nil
+}
+
+func (c *client) task(id string) (Task, error) {
+	var t Task
+	data, err := c.do(http.MethodGet, "api/task", url.Values{"id": {id}}, nil)
+	if err != nil {
+		return t, err
+	}
+	return t, json.Unmarshal(data, &t)
+}
+
+func (c *client) save(method string, t Task) (Task, error) {
+	data, err := c.doIfMatch(method, "api/task", nil, t, t.Version)
+	if err != nil {
+		return t, err
+	}
+	var saved struct {
+		ID        json.Number `json:"id"`
+		Date      string      `json:"date"`
+		Title     string      `json:"title"`
+		Comment   string      `json:"comment"`
+		Repeat    string      `json:"repeat"`
+		Priority  string      `json:"priority"`
+		Tags      []string    `json:"tags"`
+		BlockedBy []string    `json:"blocked_by"`
+		Version   string      `json:"version"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return t, err
+	}
+	return Task{
+		ID:        saved.ID.String(),
+		Date:      saved.Date,
+		Title:     saved.Title,
+		Comment:   saved.Comment,
+		Repeat:    saved.Repeat,
+		Priority:  saved.Priority,
+		Tags:      saved.Tags,
+		BlockedBy: saved.BlockedBy,
+		Version:   saved.Version,
+	}, nil
+}
+
+func (c *client) done(id string, force bool) error {
+	query := url.Values{"id": {id}}
+	if force {
+		query.Set("force", "true")
+	}
+	_, err := c.do(http.MethodPost, "api/task/done", query, nil)
+	return err
+}
+
+func (c *client) remove(id string) error {
+	_, err := c.do(http.MethodDelete, "api/task", url.Values{"id": {id}}, nil)
+	return err
+}
+
+func (c *client) nextDate(now, date, repeat string) (string, error) {
+	data, err := c.do(http.MethodGet, "api/nextdate", url.Values{
+		"now":    {now},
+		"date":   {date},
+		"repeat": {repeat},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (c *client) signIn(password string) (string, error) {
+	data, err := c.do(http.MethodPost, "api/signin", nil, map[string]string{"password": password})
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", err
+	}
+	if resp.Token == "" {
+		return "", errors.New("сервер не вернул токен")
+	}
+	return resp.Token, nil
+}
+
+func tokenFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "todo", "token"), nil
+}
+
+func loadToken() string {
+	if token := os.Getenv("TODO_TOKEN"); token != "" {
+		return token
+	}
+	path, err := tokenFile()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func saveToken(token string) error {
+	path, err := tokenFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(token), 0o600)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Использование: todo [-server URL] [-json] <команда> [аргументы]
+
+Команды:
+  add "заголовок" [-date ГГГГММДД] [-repeat ПРАВИЛО] [-comment ТЕКСТ] [-priority ПРИОРИТЕТ] [-tags ТЕГ,ТЕГ] [-after ID,ID]
+  ls [-search ТЕКСТ] [-tag ТЕГ,ТЕГ] [-sort date|priority|title|created] [-order asc|desc]
+  show ID
+  edit ID [-title ТЕКСТ] [-date ГГГГММДД] [-repeat ПРАВИЛО] [-comment ТЕКСТ] [-priority ПРИОРИТЕТ] [-tags ТЕГ,ТЕГ] [-after ID,ID]
+  done ID [-force]
+  rm ID
+  next -date ГГГГММДД -repeat ПРАВИЛО [-now ГГГГММДД]
+  login [-token ТОКЕН]
+
+Адрес сервера берётся из -server или TODO_URL, токен - из TODO_TOKEN
+или файла, сохранённого командой login.
+`
+
+type app struct {
+	client *client
+	json   bool
+	out    io.Writer
+}
+
+func main() {
+	fs := flag.NewFlagSet("todo", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), usage) }
+	server := fs.String("server", envOr("TODO_URL", "http://localhost:7540"), "адрес сервера")
+	asJSON := fs.Bool("json", false, "выводить ответ в формате JSON")
+	fs.Parse(os.Args[1:])
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	a := &app{
+		client: newClient(*server, loadToken()),
+		json:   *asJSON,
+		out:    os.Stdout,
+	}
+	if err := a.run(fs.Arg(0), fs.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка:", err)
+		os.Exit(1)
+	}
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// parse разбирает флаги, стоящие в любом месте после команды,
+// и возвращает позиционные аргументы.
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func (a *app) run(cmd string, args []string) error {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	switch cmd {
+	case "add":
+		date := fs.String("date", "", "дата задачи")
+		repeat := fs.String("repeat", "", "правило повторения")
+		comment := fs.String("comment", "", "комментарий")
+		priority := fs.String("priority", "", "приоритет: low, normal, high, urgent")
+		tags := fs.String("tags", "", "теги через запятую")
+		after := fs.String("after", "", "задачи, которые нужно выполнить раньше (id через запятую)")
+		rest, err := parse(fs, args)
+		if err != nil {
+			return err
+		}
+		if len(rest) == 0 {
+			return errors.New("не указан заголовок задачи")
+		}
+		t, err := a.client.save("POST", Task{
+			Date:      *date,
+			Title:     strings.Join(rest, " "),
+			Comment:   *comment,
+			Repeat:    *repeat,
+			Priority:  *priority,
+			Tags:      splitTags(*tags),
+			BlockedBy: splitTags(*after),
+		})
+		if err != nil {
+			return err
+		}
+		return a.printTasks([]Task{t})
+
+	case "ls":
+		search := fs.String("search", "", "строка поиска")
+		sort := fs.String("sort", "", "поле сортировки: date, priority, title, created")
+		order := fs.String("order", "", "направление сортировки: asc или desc")
+		tag := fs.String("tag", "", "только задачи со всеми этими тегами (через запятую)")
+		if _, err := parse(fs, args); err != nil {
+			return err
+		}
+		tasks, raw, err := a.client.tasks(*search, *sort, *order, splitTags(*tag))
+		if err != nil {
+			return err
+		}
+		if a.json {
+			_, err = a.out.Write(raw)
+			return err
+		}
+		return a.printTasks(tasks)
+
+	case "show":
+		rest, err := parse(fs, args)
+		if err != nil {
+			return err
+		}
+		id, err := oneID(rest)
+		if err != nil {
+			return err
+		}
+		t, err := a.client.task(id)
+		if err != nil {
+			return err
+		}
+		return a.printTasks([]Task{t})
+
+	case "edit":
+		title := fs.String("title", "", "новый заголовок")
+		date := fs.String("date", "", "новая дата")
+		repeat := fs.String("repeat", "", "новое правило повторения")
+		comment := fs.String("comment", "", "новый комментарий")
+		priority := fs.String("priority", "", "новый приоритет")
+		tags := fs.String("tags", "", "новые теги через запятую (пустая строка - снять все)")
+		after := fs.String("after", "", "новые зависимости через запятую (пустая строка - снять все)")
+		rest, err := parse(fs, args)
+		if err != nil {
+			return err
+		}
+		id, err := oneID(rest)
+		if err != nil {
+			return err
+		}
+		t, err := a.client.task(id)
+		if err != nil {
+			return err
+		}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "title":
+				t.Title = *title
+			case "date":
+				t.Date = *date
+			case "repeat":
+				t.Repeat = *repeat
+			case "comment":
+				t.Comment = *comment
+			case "priority":
+				t.Priority = *priority
+			case "tags":
+				t.Tags = splitTags(*tags)
+				if t.Tags == nil {
+					t.Tags = []string{}
+				}
+			case "after":
+				t.BlockedBy = splitTags(*after)
+				if t.BlockedBy == nil {
+					t.BlockedBy = []string{}
+				}
+			}
+		})
+		t, err = a.client.save("PUT", t)
+		if err != nil {
+			return err
+		}
+		return a.printTasks([]Task{t})
+
+	case "done", "rm":
+		var force *bool
+		if cmd == "done" {
+			force = fs.Bool("force", false, "выполнить, даже если задача ждёт другие")
+		}
+		rest, err := parse(fs, args)
+		if err != nil {
+			return err
+		}
+		id, err := oneID(rest)
+		if err != nil {
+			return err
+		}
+		if cmd == "done" {
+			err = a.client.done(id, *force)
+		} else {
+			err = a.client.remove(id)
+		}
+		if err != nil {
+			return err
+		}
+		return a.printStatus(id, "ok")
+
+	case "next":
+		now := fs.String("now", time.Now().Format("20060102"), "дата отсчёта")
+		date := fs.String("date", "", "дата задачи")
+		repeat := fs.String("repeat", "", "правило повторения")
+		if _, err := parse(fs, args); err != nil {
+			return err
+		}
+		next, err := a.client.nextDate(*now, *date, *repeat)
+		if err != nil {
+			return err
+		}
+		if a.json {
+			return json.NewEncoder(a.out).Encode(map[string]string{"date": next})
+		}
+		fmt.Fprintln(a.out, next)
+		return nil
+
+	case "login":
+		token := fs.String("token", "", "готовый токен")
+		if _, err := parse(fs, args); err != nil {
+			return err
+		}
+		if *token == "" {
+			fmt.Fprint(os.Stderr, "Пароль: ")
+			password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && password == "" {
+				return err
+			}
+			*token, err = a.client.signIn(strings.TrimSpace(password))
+			if err != nil {
+				return err
+			}
+		}
+		if err := saveToken(*token); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Токен сохранён")
+		return nil
+
+	default:
+		return fmt.Errorf("неизвестная команда %q", cmd)
+	}
+}
+
+func oneID(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("нужно указать один идентификатор задачи")
+	}
+	return args[0], nil
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (a *app) printTasks(tasks []Task) error {
+	if a.json {
+		if len(tasks) == 1 {
+			return json.NewEncoder(a.out).Encode(tasks[0])
+		}
+		return json.NewEncoder(a.out).Encode(map[string][]Task{"tasks": tasks})
+	}
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tДАТА\tЗАГОЛОВОК\tПОВТОР\tПРИОРИТЕТ\tТЕГИ\tКОММЕНТАРИЙ")
+	for _, t := range tasks {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.Date, t.Title, t.Repeat, t.Priority, strings.Join(t.Tags, ","), t.Comment)
+	}
+	return w.Flush()
+}
+
+func (a *app) printStatus(id, status string) error {
+	if a.json {
+		return json.NewEncoder(a.out).Encode(map[string]string{"id": id, "status": status})
+	}
+	fmt.Fprintln(a.out, status)
+	return nil
+}
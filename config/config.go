@@ -1,7 +1,382 @@
 package config
 
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_final_project/db"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Значения по умолчанию. Их же используют тесты из tests/.
 var Port = 7540
 var DBFile = "data/scheduler.db"
-var FullNextDate = false
+var FullNextDate = true
 var Search = false
 var Token = ``
+
+// Config собирается по возрастанию приоритета: значения по умолчанию,
+// YAML-файл (-config или TODO_CONFIG), переменные окружения, флаги.
+type Config struct {
+	Port                int           `yaml:"port"`
+	AdminToken          string        `yaml:"admin_token"`
+	DBFile              string        `yaml:"db_file"`
+	DSN                 string        `yaml:"dsn"`
+	DBBusyTimeout       time.Duration `yaml:"db_busy_timeout"`
+	DBMaxOpenConns      int           `yaml:"db_max_open_conns"`
+	WebDir              string        `yaml:"web_dir"`
+	ReadTimeout         time.Duration `yaml:"read_timeout"`
+	WriteTimeout        time.Duration `yaml:"write_timeout"`
+	IdleTimeout         time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout     time.Duration `yaml:"shutdown_timeout"`
+	ShutdownDrainDelay  time.Duration `yaml:"shutdown_drain_delay"`
+	TLSCert             string        `yaml:"tls_cert"`
+	TLSKey              string        `yaml:"tls_key"`
+	RedirectPort        int           `yaml:"redirect_port"`
+	HSTSMaxAge          time.Duration `yaml:"hsts_max_age"`
+	BackupDir           string        `yaml:"backup_dir"`
+	BackupInterval      time.Duration `yaml:"backup_interval"`
+	BackupKeep          int           `yaml:"backup_keep"`
+	BackupMaxAge        time.Duration `yaml:"backup_max_age"`
+	RequireIfMatch      bool          `yaml:"require_if_match"`
+	ReminderInterval    time.Duration `yaml:"reminder_interval"`
+	ReminderLead        time.Duration `yaml:"reminder_lead"`
+	ReminderFile        string        `yaml:"reminder_file"`
+	ReminderWebhook     string        `yaml:"reminder_webhook"`
+	SMTPAddr            string        `yaml:"smtp_addr"`
+	SMTPFrom            string        `yaml:"smtp_from"`
+	SMTPTo              string        `yaml:"smtp_to"`
+	SMTPUsername        string        `yaml:"smtp_username"`
+	SMTPPassword        string        `yaml:"smtp_password"`
+	WebhookInterval     time.Duration `yaml:"webhook_interval"`
+	WebhookMaxAttempts  int           `yaml:"webhook_max_attempts"`
+	WebhookRetryDelay   time.Duration `yaml:"webhook_retry_delay"`
+	WebhookAllowPrivate bool          `yaml:"webhook_allow_private"`
+	TrustedProxies      string        `yaml:"trusted_proxies"`
+	BotToken            string        `yaml:"bot_token"`
+	BotAPIURL           string        `yaml:"bot_api_url"`
+	BotChats            string        `yaml:"bot_chats"`
+}
+
+func Default() Config {
+	return Config{
+		Port:               Port,
+		DBFile:             DBFile,
+		WebDir:             "web",
+		DBBusyTimeout:      5 * time.Second,
+		ReadTimeout:        15 * time.Second,
+		WriteTimeout:       30 * time.Second,
+		IdleTimeout:        60 * time.Second,
+		ShutdownTimeout:    20 * time.Second,
+		ShutdownDrainDelay: 5 * time.Second,
+		HSTSMaxAge:         365 * 24 * time.Hour,
+		BackupDir:          "data/backups",
+		BackupKeep:         7,
+		WebhookInterval:    10 * time.Second,
+		WebhookMaxAttempts: 8,
+		WebhookRetryDelay:  30 * time.Second,
+		BotAPIURL:          "https://api.telegram.org",
+	}
+}
+
+func (c *Config) bind(fs *flag.FlagSet) {
+	fs.IntVar(&c.Port, "port", c.Port, "порт HTTP-сервера")
+	fs.StringVar(&c.DBFile, "db", c.DBFile, "путь к файлу базы данных")
+	fs.StringVar(&c.DSN, "dsn", c.DSN, "строка подключения к базе (postgres://...), имеет приоритет над -db")
+	fs.DurationVar(&c.DBBusyTimeout, "db-busy-timeout", c.DBBusyTimeout, "сколько SQLite ждёт снятия блокировки")
+	fs.IntVar(&c.DBMaxOpenConns, "db-max-open-conns", c.DBMaxOpenConns, "размер пула соединений (0 - по хранилищу: 1 для SQLite)")
+	fs.StringVar(&c.WebDir, "web", c.WebDir, "директория с файлами фронтенда")
+	fs.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, "таймаут чтения запроса")
+	fs.DurationVar(&c.WriteTimeout, "write-timeout", c.WriteTimeout, "таймаут записи ответа")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "таймаут простоя keep-alive соединения")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "время на завершение запросов при остановке")
+	fs.DurationVar(&c.ShutdownDrainDelay, "shutdown-drain-delay", c.ShutdownDrainDelay, "сколько сервер принимает запросы после сигнала, отвечая 503 на /readyz")
+	fs.StringVar(&c.TLSCert, "tls-cert", c.TLSCert, "файл TLS-сертификата")
+	fs.StringVar(&c.TLSKey, "tls-key", c.TLSKey, "файл закрытого ключа TLS")
+	fs.IntVar(&c.RedirectPort, "redirect-port", c.RedirectPort, "порт для перенаправления HTTP -> HTTPS (0 - выключено)")
+	fs.DurationVar(&c.HSTSMaxAge, "hsts-max-age", c.HSTSMaxAge, "max-age заголовка Strict-Transport-Security")
+	fs.StringVar(&c.BackupDir, "backup-dir", c.BackupDir, "директория для резервных копий")
+	fs.DurationVar(&c.BackupInterval, "backup-interval", c.BackupInterval, "период резервного копирования (0 - выключено)")
+	fs.IntVar(&c.BackupKeep, "backup-keep", c.BackupKeep, "сколько последних копий хранить (0 - все)")
+	fs.DurationVar(&c.BackupMaxAge, "backup-max-age", c.BackupMaxAge, "максимальный возраст копий (0 - без ограничения)")
+	fs.BoolVar(&c.RequireIfMatch, "require-if-match", c.RequireIfMatch, "требовать If-Match при изменении и удалении задач")
+	fs.DurationVar(&c.ReminderInterval, "reminder-interval", c.ReminderInterval, "период проверки напоминаний (0 - выключено)")
+	fs.DurationVar(&c.ReminderLead, "reminder-lead", c.ReminderLead, "за сколько до даты задачи напоминать (0 - в день задачи)")
+	fs.StringVar(&c.ReminderFile, "reminder-file", c.ReminderFile, "файл для напоминаний в формате JSON lines (- - стандартный вывод)")
+	fs.StringVar(&c.ReminderWebhook, "reminder-webhook", c.ReminderWebhook, "URL, на который отправляются напоминания")
+	fs.StringVar(&c.SMTPAddr, "smtp-addr", c.SMTPAddr, "адрес SMTP-сервера для напоминаний (host:port)")
+	fs.StringVar(&c.SMTPFrom, "smtp-from", c.SMTPFrom, "адрес отправителя напоминаний")
+	fs.StringVar(&c.SMTPTo, "smtp-to", c.SMTPTo, "получатели напоминаний через запятую")
+	fs.StringVar(&c.SMTPUsername, "smtp-username", c.SMTPUsername, "логин SMTP (пароль - только в файле или TODO_SMTP_PASSWORD)")
+	fs.DurationVar(&c.WebhookInterval, "webhook-interval", c.WebhookInterval, "период проверки очереди вебхуков (0 - не отправлять)")
+	fs.IntVar(&c.WebhookMaxAttempts, "webhook-max-attempts", c.WebhookMaxAttempts, "сколько раз пытаться доставить событие (0 - без ограничения)")
+	fs.DurationVar(&c.WebhookRetryDelay, "webhook-retry-delay", c.WebhookRetryDelay, "задержка перед первым повтором, дальше удваивается")
+	fs.BoolVar(&c.WebhookAllowPrivate, "webhook-allow-private", c.WebhookAllowPrivate, "разрешить вебхуки на локальные и внутренние адреса")
+	fs.StringVar(&c.TrustedProxies, "trusted-proxies", c.TrustedProxies, "адреса или подсети прокси через запятую, которым доверяется заголовок X-Actor")
+	fs.StringVar(&c.BotAPIURL, "bot-api-url", c.BotAPIURL, "адрес Telegram Bot API")
+	fs.StringVar(&c.BotChats, "bot-chats", c.BotChats, "идентификаторы чатов, из которых бот принимает команды, через запятую (пусто - ни одного)")
+}
+
+// Load регистрирует флаги конфигурации в fs, разбирает args и собирает
+// итоговый Config. Собственные флаги команды caller добавляет в fs заранее.
+func Load(fs *flag.FlagSet, args []string) (Config, error) {
+	path := fs.String("config", os.Getenv("TODO_CONFIG"), "YAML-файл конфигурации")
+	parsed := Default()
+	parsed.bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := Default()
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return Config{}, err
+	}
+
+	final := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	cfg.bind(final)
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if final.Lookup(f.Name) != nil && err == nil {
+			err = final.Set(f.Name, f.Value.String())
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении файла конфигурации: %w", err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("ошибка в файле конфигурации %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	strs := map[string]*string{
+		"TODO_ADMIN_TOKEN":      &c.AdminToken,
+		"TODO_DBFILE":           &c.DBFile,
+		"TODO_DSN":              &c.DSN,
+		"TODO_WEB_DIR":          &c.WebDir,
+		"TODO_TLS_CERT":         &c.TLSCert,
+		"TODO_TLS_KEY":          &c.TLSKey,
+		"TODO_BACKUP_DIR":       &c.BackupDir,
+		"TODO_REMINDER_FILE":    &c.ReminderFile,
+		"TODO_REMINDER_WEBHOOK": &c.ReminderWebhook,
+		"TODO_SMTP_ADDR":        &c.SMTPAddr,
+		"TODO_SMTP_FROM":        &c.SMTPFrom,
+		"TODO_SMTP_TO":          &c.SMTPTo,
+		"TODO_SMTP_USERNAME":    &c.SMTPUsername,
+		"TODO_SMTP_PASSWORD":    &c.SMTPPassword,
+		"TODO_BOT_TOKEN":        &c.BotToken,
+		"TODO_BOT_API_URL":      &c.BotAPIURL,
+		"TODO_BOT_CHATS":        &c.BotChats,
+		"TODO_TRUSTED_PROXIES":  &c.TrustedProxies,
+	}
+	for name, dst := range strs {
+		if v := os.Getenv(name); v != "" {
+			*dst = v
+		}
+	}
+
+	ints := map[string]*int{
+		"TODO_PORT":                 &c.Port,
+		"TODO_HTTP_REDIRECT_PORT":   &c.RedirectPort,
+		"TODO_BACKUP_KEEP":          &c.BackupKeep,
+		"TODO_DB_MAX_OPEN_CONNS":    &c.DBMaxOpenConns,
+		"TODO_WEBHOOK_MAX_ATTEMPTS": &c.WebhookMaxAttempts,
+	}
+	for name, dst := range ints {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("неверное значение %s: %w", name, err)
+			}
+			*dst = n
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"TODO_READ_TIMEOUT":         &c.ReadTimeout,
+		"TODO_DB_BUSY_TIMEOUT":      &c.DBBusyTimeout,
+		"TODO_WRITE_TIMEOUT":        &c.WriteTimeout,
+		"TODO_IDLE_TIMEOUT":         &c.IdleTimeout,
+		"TODO_SHUTDOWN_TIMEOUT":     &c.ShutdownTimeout,
+		"TODO_SHUTDOWN_DRAIN_DELAY": &c.ShutdownDrainDelay,
+		"TODO_HSTS_MAX_AGE":         &c.HSTSMaxAge,
+		"TODO_BACKUP_INTERVAL":      &c.BackupInterval,
+		"TODO_BACKUP_MAX_AGE":       &c.BackupMaxAge,
+		"TODO_REMINDER_INTERVAL":    &c.ReminderInterval,
+		"TODO_REMINDER_LEAD":        &c.ReminderLead,
+		"TODO_WEBHOOK_INTERVAL":     &c.WebhookInterval,
+		"TODO_WEBHOOK_RETRY_DELAY":  &c.WebhookRetryDelay,
+	}
+	for name, dst := range durations {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("неверное значение %s: %w", name, err)
+			}
+			*dst = d
+		}
+	}
+
+	bools := map[string]*bool{
+		"TODO_REQUIRE_IF_MATCH":      &c.RequireIfMatch,
+		"TODO_WEBHOOK_ALLOW_PRIVATE": &c.WebhookAllowPrivate,
+	}
+	for name, dst := range bools {
+		if v := os.Getenv(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("неверное значение %s: %w", name, err)
+			}
+			*dst = b
+		}
+	}
+	return nil
+}
+
+// Database возвращает строку подключения: DSN, если он задан, иначе путь к файлу SQLite.
+func (c Config) Database() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	return c.DBFile
+}
+
+func (c Config) DBOptions() db.Options {
+	return db.Options{BusyTimeout: c.DBBusyTimeout, MaxOpenConns: c.DBMaxOpenConns}
+}
+
+// SMTPRecipients разбирает список получателей из smtp_to.
+func (c Config) SMTPRecipients() []string {
+	var to []string
+	for _, addr := range strings.Split(c.SMTPTo, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return to
+}
+
+// BotChatIDs разбирает список разрешённых чатов из bot_chats.
+func (c Config) BotChatIDs() ([]int64, error) {
+	var ids []int64
+	for _, s := range strings.Split(c.BotChats, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("неверный идентификатор чата %s", s)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// TrustedProxyNets разбирает trusted_proxies: отдельные адреса и подсети в нотации CIDR.
+func (c Config) TrustedProxyNets() ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range strings.Split(c.TrustedProxies, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("неверный адрес доверенного прокси %s", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("неверная подсеть доверенного прокси %s", s)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func (c Config) TLS() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("неверный порт %d", c.Port))
+	}
+	if c.DBFile == "" && c.DSN == "" {
+		errs = append(errs, errors.New("не указан файл базы данных"))
+	}
+	if c.WebDir == "" {
+		errs = append(errs, errors.New("не указана директория фронтенда"))
+	}
+	if c.DBBusyTimeout < 0 || c.DBMaxOpenConns < 0 {
+		errs = append(errs, errors.New("параметры пула соединений не могут быть отрицательными"))
+	}
+	if c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0 || c.ShutdownTimeout < 0 || c.ShutdownDrainDelay < 0 {
+		errs = append(errs, errors.New("таймауты не могут быть отрицательными"))
+	}
+	if c.BackupInterval < 0 || c.BackupMaxAge < 0 || c.BackupKeep < 0 {
+		errs = append(errs, errors.New("параметры резервного копирования не могут быть отрицательными"))
+	}
+	if c.BackupInterval > 0 && c.BackupDir == "" {
+		errs = append(errs, errors.New("не указана директория для резервных копий"))
+	}
+	if c.BackupInterval > 0 && db.DialectOf(c.Database()) == db.Postgres {
+		errs = append(errs, errors.New("резервное копирование по расписанию доступно только для SQLite"))
+	}
+	if c.ReminderInterval < 0 || c.ReminderLead < 0 {
+		errs = append(errs, errors.New("параметры напоминаний не могут быть отрицательными"))
+	}
+	if c.WebhookInterval < 0 || c.WebhookMaxAttempts < 0 || c.WebhookRetryDelay < 0 {
+		errs = append(errs, errors.New("параметры вебхуков не могут быть отрицательными"))
+	}
+	if c.SMTPAddr != "" && (c.SMTPFrom == "" || len(c.SMTPRecipients()) == 0) {
+		errs = append(errs, errors.New("для отправки напоминаний по почте нужно указать отправителя и получателей"))
+	}
+	if _, err := c.BotChatIDs(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.TrustedProxyNets(); err != nil {
+		errs = append(errs, err)
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, errors.New("для HTTPS нужно указать и сертификат, и ключ"))
+	}
+	if c.RedirectPort != 0 {
+		if !c.TLS() {
+			errs = append(errs, errors.New("перенаправление на HTTPS требует TLS-сертификата"))
+		}
+		if c.RedirectPort < 0 || c.RedirectPort > 65535 || c.RedirectPort == c.Port {
+			errs = append(errs, fmt.Errorf("неверный порт перенаправления %d", c.RedirectPort))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrNotSupported = errors.New("операция поддерживается только для SQLite")
+
+// ErrLocked - база открыта другим процессом или восстанавливается.
+var ErrLocked = errors.New("база данных занята")
+
+func Backup(db *DB, dest string) error {
+	if db.Dialect != SQLite {
+		return fmt.Errorf("%w, для PostgreSQL используйте pg_dump", ErrNotSupported)
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("файл %s уже существует", dest)
+	}
+	if dir := filepath.Dir(dest); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	// VACUUM INTO долго читает всю базу. В пуле SQLite одно соединение, поэтому
+	// снимок делается через отдельное: в режиме WAL чтение не мешает запросам.
+	conn, err := sql.Open("sqlite", db.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(`VACUUM INTO ?`, dest); err != nil {
+		return fmt.Errorf("ошибка при создании резервной копии: %w", err)
+	}
+	return nil
+}
+
+func Vacuum(db *DB) error {
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("ошибка при выполнении VACUUM: %w", err)
+	}
+	return nil
+}
+
+func IntegrityCheck(db *DB) error {
+	if db.Dialect != SQLite {
+		return db.Ping()
+	}
+	rows, err := db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return fmt.Errorf("ошибка при проверке целостности: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("база данных повреждена: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+var schedulerColumns = []string{"id", "date", "title", "comment", "repeat"}
+
+// ValidateSnapshot проверяет, что файл src - целая база планировщика
+// со схемой, которую понимает эта версия сервера.
+func ValidateSnapshot(src string) error {
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	conn, err := sql.Open("sqlite", "file:"+src+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	snapshot := &DB{DB: conn, Dialect: SQLite}
+
+	if err := IntegrityCheck(snapshot); err != nil {
+		return err
+	}
+
+	version, err := Version(snapshot)
+	if err != nil {
+		return err
+	}
+	if version > LatestVersion() {
+		return fmt.Errorf("неподдерживаемая версия схемы %d", version)
+	}
+
+	rows, err := snapshot.Query(`SELECT name FROM pragma_table_info('scheduler')`)
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении схемы: %w", err)
+	}
+	defer rows.Close()
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, c := range schedulerColumns {
+		if !columns[c] {
+			return fmt.Errorf("в таблице scheduler нет столбца %s", c)
+		}
+	}
+	return nil
+}
+
+// Restore заменяет файл базы dbFile копией src. Если база открыта
+// (запущен сервер), возвращается ErrLocked: подменять файл под живыми
+// соединениями и удалять их WAL нельзя.
+func Restore(src, dbFile string) error {
+	if DialectOf(dbFile) != SQLite {
+		return ErrNotSupported
+	}
+	if err := ValidateSnapshot(src); err != nil {
+		return fmt.Errorf("резервная копия %s: %w", src, err)
+	}
+
+	dbFile = Path(strings.TrimPrefix(dbFile, "sqlite://"))
+	lock, err := lockFile(dbFile, true)
+	if errors.Is(err, ErrLocked) {
+		return fmt.Errorf("база %s открыта, остановите сервер перед восстановлением: %w", dbFile, err)
+	} else if err != nil {
+		return err
+	}
+	if lock != nil {
+		defer lock.Close()
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dbFile + ".restore"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		os.Remove(dbFile + suffix)
+	}
+	return os.Rename(tmp, dbFile)
+}
+
+type Task struct {
+	ID       int64  `json:"id"`
+	Date     string `json:"date"`
+	Title    string `json:"title"`
+	Comment  string `json:"comment"`
+	Repeat   string `json:"repeat"`
+	Priority string `json:"priority"`
+}
+
+func AllTasks(db *DB) ([]Task, error) {
+	rows, err := db.Query(`SELECT id, date, title, comment, repeat, priority FROM scheduler ORDER BY date, id`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении задач: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := []Task{}
+	for rows.Next() {
+		var t Task
+		if err := rows.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat, &t.Priority); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
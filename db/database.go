@@ -4,42 +4,101 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	_ "github.com/lib/pq"
 	_ "modernc.org/sqlite"
 )
 
-func InitDB() (*sql.DB, error) {
-	appPath, err := os.Getwd()
+func InitDB(source string, opts Options) (*DB, error) {
+	db, err := Open(source, opts)
 	if err != nil {
-		log.Fatal("Ошибка при получении рабочей директории: ", err)
+		log.Fatal("Ошибка при открытии базы данных: ", err)
 	}
 
-	dbFile := filepath.Join(appPath, "data/scheduler.db")
+	if err := Migrate(db); err != nil {
+		log.Fatal("Ошибка при создании базы данных: ", err)
+	}
 
-	_, err = os.Stat(dbFile)
+	return db, nil
+}
 
-	var install bool
+type Options struct {
+	// BusyTimeout - сколько SQLite ждёт освобождения блокировки,
+	// прежде чем вернуть SQLITE_BUSY.
+	BusyTimeout time.Duration
+	// MaxOpenConns - размер пула соединений. 0 - выбрать по хранилищу:
+	// для SQLite одно соединение, чтобы писатель был единственным.
+	MaxOpenConns int
+}
+
+func Path(dbFile string) string {
+	if filepath.IsAbs(dbFile) {
+		return dbFile
+	}
+	appPath, err := os.Getwd()
 	if err != nil {
-		install = true
+		log.Fatal("Ошибка при получении рабочей директории: ", err)
 	}
+	return filepath.Join(appPath, dbFile)
+}
 
-	db, err := sql.Open("sqlite", dbFile)
-	if err != nil {
-		log.Fatal("Ошибка при открытии базы данных: ", err)
+// DialectOf определяет хранилище по строке подключения: postgres:// и
+// postgresql:// - PostgreSQL, всё остальное - путь к файлу SQLite
+// (допускается префикс sqlite://).
+func DialectOf(source string) Dialect {
+	if strings.HasPrefix(source, "postgres://") || strings.HasPrefix(source, "postgresql://") {
+		return Postgres
 	}
+	return SQLite
+}
 
-	if install {
-		if err := createTables(db); err != nil {
-			log.Fatal("Ошибка при создании базы данных: ", err)
+func Open(source string, opts Options) (*DB, error) {
+	dialect := DialectOf(source)
+	var (
+		conn *sql.DB
+		lock *os.File
+		dsn  string
+		err  error
+	)
+	switch dialect {
+	case Postgres:
+		conn, err = sql.Open("postgres", source)
+	default:
+		params := url.Values{}
+		params.Add("_pragma", "journal_mode(WAL)")
+		params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", opts.BusyTimeout.Milliseconds()))
+		params.Add("_pragma", "foreign_keys(1)")
+		params.Add("_pragma", "synchronous(NORMAL)")
+		params.Set("_txlock", "immediate")
+		path := Path(strings.TrimPrefix(source, "sqlite://"))
+		if lock, err = lockFile(path, false); err != nil {
+			return nil, fmt.Errorf("база данных %s: %w", path, err)
+		}
+		dsn = "file:" + path + "?" + params.Encode()
+		conn, err = sql.Open("sqlite", dsn)
+		if err == nil && opts.MaxOpenConns == 0 {
+			opts.MaxOpenConns = 1
 		}
 	}
-
-	return db, nil
+	if err != nil {
+		if lock != nil {
+			lock.Close()
+		}
+		return nil, err
+	}
+	if opts.MaxOpenConns > 0 {
+		conn.SetMaxOpenConns(opts.MaxOpenConns)
+		conn.SetMaxIdleConns(opts.MaxOpenConns)
+	}
+	return &DB{DB: conn, Dialect: dialect, lock: lock, dsn: dsn}, nil
 }
 
-func createTables(db *sql.DB) error {
+func createTables(tx *Tx) error {
 	sqlCreateTable := `
     CREATE TABLE IF NOT EXISTS scheduler (
         id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -48,21 +107,239 @@ func createTables(db *sql.DB) error {
         comment TEXT,
         repeat TEXT CHECK(length(repeat) <= 128)
     );`
+	if tx.Dialect == Postgres {
+		sqlCreateTable = `
+    CREATE TABLE IF NOT EXISTS scheduler (
+        id BIGSERIAL PRIMARY KEY,
+        date TEXT NOT NULL,
+        title TEXT NOT NULL,
+        comment TEXT,
+        repeat TEXT CHECK(length(repeat) <= 128)
+    );`
+	}
 
 	sqlCreateIndex := `
 	CREATE INDEX IF NOT EXISTS idx_scheduler_date ON scheduler(date);`
 
-	_, err := db.Exec(sqlCreateTable)
+	_, err := tx.Exec(sqlCreateTable)
 	if err != nil {
 		return fmt.Errorf("ошибка при создании таблицы: %w", err)
 	}
-	fmt.Println("Таблица scheduler успешно создана")
 
-	_, err = db.Exec(sqlCreateIndex)
+	_, err = tx.Exec(sqlCreateIndex)
 	if err != nil {
 		return fmt.Errorf("ошибка при создании индекса: %w", err)
 	}
-	fmt.Println("Индекс по столбцу date успешно создан")
 
 	return nil
 }
+
+func addTaskVersion(tx *Tx) error {
+	_, err := tx.Exec(`ALTER TABLE scheduler ADD COLUMN version INTEGER NOT NULL DEFAULT 1`)
+	if err != nil {
+		return fmt.Errorf("ошибка при добавлении столбца version: %w", err)
+	}
+	return nil
+}
+
+// addAuditLog создаёт журнал изменений задач. Записи в нём только
+// добавляются: изменить или удалить их не дают триггеры.
+func addAuditLog(tx *Tx) error {
+	sqlCreateTable := `
+    CREATE TABLE IF NOT EXISTS audit_log (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        task_id INTEGER NOT NULL,
+        actor TEXT NOT NULL,
+        action TEXT NOT NULL,
+        created_at TEXT NOT NULL,
+        diff TEXT NOT NULL
+    );`
+	sqlCreateTriggers := []string{`
+    CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+    BEGIN SELECT RAISE(ABORT, 'audit_log: изменение записей запрещено'); END;`, `
+    CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+    BEGIN SELECT RAISE(ABORT, 'audit_log: удаление записей запрещено'); END;`,
+	}
+	if tx.Dialect == Postgres {
+		sqlCreateTable = `
+    CREATE TABLE IF NOT EXISTS audit_log (
+        id BIGSERIAL PRIMARY KEY,
+        task_id BIGINT NOT NULL,
+        actor TEXT NOT NULL,
+        action TEXT NOT NULL,
+        created_at TEXT NOT NULL,
+        diff TEXT NOT NULL
+    );`
+		sqlCreateTriggers = []string{`
+    CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+    BEGIN RAISE EXCEPTION 'audit_log: изменение и удаление записей запрещены'; END;
+    $$ LANGUAGE plpgsql;`, `
+    CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();`,
+		}
+	}
+
+	_, err := tx.Exec(sqlCreateTable)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании таблицы audit_log: %w", err)
+	}
+
+	_, err = tx.Exec(`CREATE INDEX IF NOT EXISTS idx_audit_log_task ON audit_log(task_id, created_at);`)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании индекса: %w", err)
+	}
+
+	for _, query := range sqlCreateTriggers {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("ошибка при создании триггера: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func addTaskPriority(tx *Tx) error {
+	_, err := tx.Exec(`ALTER TABLE scheduler ADD COLUMN priority TEXT NOT NULL DEFAULT 'normal'
+		CHECK(priority IN ('low', 'normal', 'high', 'urgent'))`)
+	if err != nil {
+		return fmt.Errorf("ошибка при добавлении столбца priority: %w", err)
+	}
+	return nil
+}
+
+func addTags(tx *Tx) error {
+	id := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if tx.Dialect == Postgres {
+		id = "BIGSERIAL PRIMARY KEY"
+	}
+	queries := []string{`
+    CREATE TABLE IF NOT EXISTS tags (
+        id ` + id + `,
+        name TEXT NOT NULL UNIQUE
+    );`, `
+    CREATE TABLE IF NOT EXISTS task_tags (
+        task_id BIGINT NOT NULL REFERENCES scheduler(id) ON DELETE CASCADE,
+        tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+        PRIMARY KEY (task_id, tag_id)
+    );`, `
+	CREATE INDEX IF NOT EXISTS idx_task_tags_tag ON task_tags(tag_id);`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("ошибка при создании таблиц тегов: %w", err)
+		}
+	}
+	return nil
+}
+
+func addTaskItems(tx *Tx) error {
+	id := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if tx.Dialect == Postgres {
+		id = "BIGSERIAL PRIMARY KEY"
+	}
+	queries := []string{`
+    CREATE TABLE IF NOT EXISTS task_items (
+        id ` + id + `,
+        task_id BIGINT NOT NULL REFERENCES scheduler(id) ON DELETE CASCADE,
+        position INTEGER NOT NULL,
+        title TEXT NOT NULL,
+        done INTEGER NOT NULL DEFAULT 0
+    );`, `
+	CREATE INDEX IF NOT EXISTS idx_task_items_task ON task_items(task_id, position);`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("ошибка при создании таблицы task_items: %w", err)
+		}
+	}
+	return nil
+}
+
+func addTaskDeps(tx *Tx) error {
+	queries := []string{`
+    CREATE TABLE IF NOT EXISTS task_deps (
+        task_id BIGINT NOT NULL REFERENCES scheduler(id) ON DELETE CASCADE,
+        depends_on BIGINT NOT NULL REFERENCES scheduler(id) ON DELETE CASCADE,
+        PRIMARY KEY (task_id, depends_on),
+        CHECK (task_id <> depends_on)
+    );`, `
+	CREATE INDEX IF NOT EXISTS idx_task_deps_depends_on ON task_deps(depends_on);`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("ошибка при создании таблицы task_deps: %w", err)
+		}
+	}
+	return nil
+}
+
+// addRemindersSent создаёт таблицу отправленных напоминаний: по каждой
+// дате задачи напоминание уходит один раз, даже после перезапуска.
+func addRemindersSent(tx *Tx) error {
+	_, err := tx.Exec(`
+    CREATE TABLE IF NOT EXISTS reminders_sent (
+        task_id BIGINT NOT NULL REFERENCES scheduler(id) ON DELETE CASCADE,
+        date TEXT NOT NULL,
+        sent_at TEXT NOT NULL,
+        PRIMARY KEY (task_id, date)
+    );`)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании таблицы reminders_sent: %w", err)
+	}
+	return nil
+}
+
+// addWebhooks создаёт подписки на события задач и очередь их доставки.
+// Доставка ставится в очередь в той же транзакции, что и изменение задачи,
+// поэтому событие не теряется при перезапуске сервера.
+func addWebhooks(tx *Tx) error {
+	id := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if tx.Dialect == Postgres {
+		id = "BIGSERIAL PRIMARY KEY"
+	}
+	queries := []string{`
+    CREATE TABLE IF NOT EXISTS webhooks (
+        id ` + id + `,
+        url TEXT NOT NULL,
+        secret TEXT NOT NULL,
+        events TEXT NOT NULL,
+        created_at TEXT NOT NULL
+    );`, `
+    CREATE TABLE IF NOT EXISTS webhook_deliveries (
+        id ` + id + `,
+        webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+        event TEXT NOT NULL,
+        payload TEXT NOT NULL,
+        status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'delivered', 'failed')),
+        attempts INTEGER NOT NULL DEFAULT 0,
+        next_attempt_at TEXT NOT NULL,
+        response_code INTEGER NOT NULL DEFAULT 0,
+        last_error TEXT NOT NULL DEFAULT '',
+        created_at TEXT NOT NULL,
+        updated_at TEXT NOT NULL
+    );`, `
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);`, `
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("ошибка при создании таблиц вебхуков: %w", err)
+		}
+	}
+	return nil
+}
+
+// addHolidays создаёт производственный календарь: праздничные дни и
+// выходные, перенесённые на рабочие (kind = 'workday').
+func addHolidays(tx *Tx) error {
+	_, err := tx.Exec(`
+    CREATE TABLE IF NOT EXISTS holidays (
+        date TEXT PRIMARY KEY,
+        kind TEXT NOT NULL DEFAULT 'holiday' CHECK(kind IN ('holiday', 'workday')),
+        title TEXT NOT NULL DEFAULT ''
+    );`)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании таблицы holidays: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	Postgres
+)
+
+func (d Dialect) String() string {
+	if d == Postgres {
+		return "postgres"
+	}
+	return "sqlite"
+}
+
+// DB - соединение с базой, которое знает свой диалект. Запросы пишутся
+// с плейсхолдерами "?", для PostgreSQL они переписываются в "$1, $2, ...".
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+
+	lock *os.File
+	// dsn - строка открытия SQLite, по ней Backup открывает отдельное соединение.
+	dsn string
+}
+
+// Close закрывает соединения и снимает блокировку файла базы.
+func (d *DB) Close() error {
+	err := d.DB.Close()
+	if d.lock != nil {
+		d.lock.Close()
+	}
+	return err
+}
+
+func (d *DB) Rebind(query string) string {
+	return d.Dialect.rebind(query)
+}
+
+func (d Dialect) rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (d *DB) Exec(query string, args ...any) (sql.Result, error) {
+	return d.DB.Exec(d.Rebind(query), args...)
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return d.DB.ExecContext(ctx, d.Rebind(query), args...)
+}
+
+func (d *DB) Query(query string, args ...any) (*sql.Rows, error) {
+	return d.DB.Query(d.Rebind(query), args...)
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.DB.QueryContext(ctx, d.Rebind(query), args...)
+}
+
+func (d *DB) QueryRow(query string, args ...any) *sql.Row {
+	return d.DB.QueryRow(d.Rebind(query), args...)
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.DB.QueryRowContext(ctx, d.Rebind(query), args...)
+}
+
+// Insert выполняет INSERT и возвращает id новой строки.
+func (d *DB) Insert(query string, args ...any) (int64, error) {
+	return insert(d.Dialect, d.DB, query, args...)
+}
+
+func (d *DB) Begin() (*Tx, error) {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, Dialect: d.Dialect}, nil
+}
+
+// Queryer - общее у DB и Tx, чтобы один и тот же код работал
+// как в транзакции, так и без неё.
+type Queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Insert(query string, args ...any) (int64, error)
+}
+
+type Tx struct {
+	*sql.Tx
+	Dialect Dialect
+}
+
+func (t *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.Tx.Exec(t.Dialect.rebind(query), args...)
+}
+
+func (t *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.Tx.Query(t.Dialect.rebind(query), args...)
+}
+
+func (t *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return t.Tx.QueryRow(t.Dialect.rebind(query), args...)
+}
+
+func (t *Tx) Insert(query string, args ...any) (int64, error) {
+	return insert(t.Dialect, t.Tx, query, args...)
+}
+
+type execQueryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func insert(d Dialect, q execQueryer, query string, args ...any) (int64, error) {
+	if d == Postgres {
+		var id int64
+		err := q.QueryRow(d.rebind(query)+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	res, err := q.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
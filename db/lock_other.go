@@ -0,0 +1,11 @@
+//go:build !unix
+
+package db
+
+import "os"
+
+// lockFile: на системах без flock база не блокируется, и останавливать
+// сервер перед restore нужно самому.
+func lockFile(path string, exclusive bool) (*os.File, error) {
+	return nil, nil
+}
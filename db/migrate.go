@@ -0,0 +1,106 @@
+package db
+
+import (
+	"fmt"
+	"log"
+)
+
+// Миграции применяются по порядку. Номер последней применённой хранится
+// в PRAGMA user_version (SQLite) или в таблице schema_version (PostgreSQL).
+// Если SQL различается, миграция сама смотрит на tx.Dialect.
+var migrations = []func(tx *Tx) error{
+	createTables,
+	addTaskVersion,
+	addAuditLog,
+	addTaskPriority,
+	addTags,
+	addTaskItems,
+	addTaskDeps,
+	addRemindersSent,
+	addWebhooks,
+	addHolidays,
+}
+
+func Version(db *DB) (int, error) {
+	var version int
+	var err error
+	switch db.Dialect {
+	case Postgres:
+		var exists bool
+		err = db.QueryRow(`SELECT to_regclass('schema_version') IS NOT NULL`).Scan(&exists)
+		if err == nil && exists {
+			err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+		}
+	default:
+		err = db.QueryRow(`PRAGMA user_version`).Scan(&version)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при чтении версии схемы: %w", err)
+	}
+	return version, nil
+}
+
+func setVersion(tx *Tx, version int) error {
+	switch tx.Dialect {
+	case Postgres:
+		if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM schema_version`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO schema_version (version) VALUES (?)`, version)
+		return err
+	default:
+		_, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version))
+		return err
+	}
+}
+
+func LatestVersion() int {
+	return len(migrations)
+}
+
+func Migrate(db *DB) error {
+	version, err := Version(db)
+	if err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("версия схемы %d новее поддерживаемой %d", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := migrations[i](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("миграция %d: %w", i+1, err)
+		}
+		if err := setVersion(tx, i+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("миграция %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("миграция %d: %w", i+1, err)
+		}
+		log.Printf("Применена миграция %d", i+1)
+	}
+	return nil
+}
+
+func CheckSchema(db *DB) error {
+	version, err := Version(db)
+	if err != nil {
+		return err
+	}
+	if version < len(migrations) {
+		return fmt.Errorf("версия схемы %d, ожидается %d: выполните команду migrate", version, len(migrations))
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("версия схемы %d новее поддерживаемой %d", version, len(migrations))
+	}
+	return nil
+}
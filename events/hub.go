@@ -0,0 +1,161 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Event - изменение задачи, которое получают подписчики /api/events.
+type Event struct {
+	ID    int64           `json:"id"`
+	Type  string          `json:"type"`
+	Time  string          `json:"time"`
+	Actor string          `json:"actor"`
+	Data  json.RawMessage `json:"task"`
+	// Origin - соединение WebSocket, из которого пришло изменение:
+	// его автору событие не отправляется, он уже получил подтверждение.
+	Origin string `json:"-"`
+}
+
+// Hub рассылает события подписчикам в пределах процесса и хранит
+// последние события, чтобы переподключившийся клиент получил пропущенное.
+type Hub struct {
+	mu      sync.Mutex
+	nextID  int64
+	history []Event
+	size    int
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+// Subscription - подписка на события. Events закрывается, если подписчик
+// не успевает их читать или хаб остановлен; клиенту нужно переподключиться.
+type Subscription struct {
+	Events chan Event
+	// Reset - события после запрошенного Last-Event-ID уже вытеснены из
+	// истории (или сервер перезапущен), и клиенту нужно перечитать список.
+	Reset bool
+
+	allow func(Event) bool
+}
+
+const subscriberBuffer = 64
+
+func NewHub(size int) *Hub {
+	return &Hub{
+		// Идентификаторы растут и между перезапусками сервера, поэтому
+		// старый Last-Event-ID не совпадёт с новыми событиями.
+		nextID: time.Now().UnixMilli(),
+		size:   size,
+		subs:   map[*Subscription]struct{}{},
+	}
+}
+
+// Default - хаб, в который пишут обработчики задач.
+var Default = NewHub(1000)
+
+func Publish(eventType, actor, origin string, task interface{}) {
+	Default.Publish(eventType, actor, origin, task)
+}
+
+func (h *Hub) Publish(eventType, actor, origin string, task interface{}) {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.nextID++
+	e := Event{
+		ID:     h.nextID,
+		Type:   eventType,
+		Time:   time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Actor:  actor,
+		Data:   data,
+		Origin: origin,
+	}
+	h.history = append(h.history, e)
+	if len(h.history) > h.size {
+		h.history = h.history[len(h.history)-h.size:]
+	}
+	for s := range h.subs {
+		if s.allow != nil && !s.allow(e) {
+			continue
+		}
+		select {
+		case s.Events <- e:
+		default:
+			// Медленный подписчик: отключаем, при переподключении он
+			// получит пропущенное по Last-Event-ID.
+			delete(h.subs, s)
+			close(s.Events)
+		}
+	}
+}
+
+// Subscribe подписывает на события после lastID (0 - только новые).
+// allow отбирает события для подписчика; nil - все события.
+func (h *Hub) Subscribe(lastID int64, allow func(Event) bool) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var missed []Event
+	reset := false
+	if lastID > 0 && lastID < h.nextID {
+		oldest := h.nextID + 1
+		if len(h.history) > 0 {
+			oldest = h.history[0].ID
+		}
+		if lastID < oldest-1 {
+			reset = true
+		}
+		for _, e := range h.history {
+			if e.ID > lastID && (allow == nil || allow(e)) {
+				missed = append(missed, e)
+			}
+		}
+	} else if lastID > h.nextID {
+		reset = true
+	}
+
+	s := &Subscription{
+		Events: make(chan Event, len(missed)+subscriberBuffer),
+		Reset:  reset,
+		allow:  allow,
+	}
+	for _, e := range missed {
+		s.Events <- e
+	}
+	if h.closed {
+		close(s.Events)
+		return s
+	}
+	h.subs[s] = struct{}{}
+	return s
+}
+
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.Events)
+	}
+}
+
+// Close отключает всех подписчиков; вызывается при остановке сервера,
+// чтобы открытые потоки не задерживали Shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		delete(h.subs, s)
+		close(s.Events)
+	}
+}
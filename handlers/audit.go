@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_final_project/db"
+	"go_final_project/metrics"
+)
+
+const auditTimeFormat = "2006-01-02T15:04:05Z"
+
+type auditChange struct {
+	Before *string `json:"before"`
+	After  *string `json:"after"`
+}
+
+// trustedProxies - адреса прокси, которым разрешено передавать автора
+// изменений в заголовке X-Actor (trusted_proxies).
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies задаёт доверенные прокси. Вызывается до запуска сервера.
+func SetTrustedProxies(nets []*net.IPNet) {
+	trustedProxies = nets
+}
+
+func trustedProxy(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// actorKey - ключ контекста с автором запросов, которые сервер выполняет
+// сам (чат-бот, WebSocket).
+type actorKey struct{}
+
+// WithActor задаёт автора изменений для запроса, выполняемого внутри процесса.
+func WithActor(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, actorKey{}, name)
+}
+
+// actorName приводит имя к допустимому UTF-8 и обрезает до 128 символов.
+func actorName(name string) string {
+	name = strings.ToValidUTF8(strings.TrimSpace(name), "")
+	if runes := []rune(name); len(runes) > 128 {
+		name = string(runes[:128])
+	}
+	return name
+}
+
+// actor - кто выполняет запрос. Входа по пользователям в сервере нет,
+// поэтому это адрес клиента. Заголовку X-Actor верим только от доверенного
+// прокси, иначе любой клиент мог бы записать в журнал чужое имя.
+func actor(r *http.Request) string {
+	if name, ok := r.Context().Value(actorKey{}).(string); ok {
+		return actorName(name)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if name := actorName(r.Header.Get("X-Actor")); name != "" && trustedProxy(host) {
+		return name
+	}
+	return host
+}
+
+// auditItems записывает чек-лист строками "[x] текст" по порядку пунктов.
+func auditItems(items []TaskItem) string {
+	lines := make([]string, len(items))
+	for i, item := range items {
+		mark := "[ ] "
+		if item.Done {
+			mark = "[x] "
+		}
+		lines[i] = mark + item.Title
+	}
+	return strings.Join(lines, "\n")
+}
+
+// auditDiff возвращает изменившиеся поля задачи. before == nil - задача
+// создана, after == nil - удалена.
+func auditDiff(before, after *Task) map[string]auditChange {
+	fields := func(t *Task) map[string]*string {
+		if t == nil {
+			return map[string]*string{"date": nil, "title": nil, "comment": nil, "repeat": nil, "priority": nil, "tags": nil, "items": nil, "blocked_by": nil}
+		}
+		tags, items, deps := strings.Join(t.Tags, ","), auditItems(t.Items), strings.Join(t.BlockedBy, ",")
+		return map[string]*string{
+			"date": &t.Date, "title": &t.Title, "comment": &t.Comment, "repeat": &t.Repeat, "priority": &t.Priority,
+			"tags": &tags, "items": &items, "blocked_by": &deps,
+		}
+	}
+	old, cur := fields(before), fields(after)
+
+	diff := map[string]auditChange{}
+	for name := range old {
+		if old[name] != nil && cur[name] != nil && *old[name] == *cur[name] {
+			continue
+		}
+		diff[name] = auditChange{Before: old[name], After: cur[name]}
+	}
+	return diff
+}
+
+func audit(tx *db.Tx, r *http.Request, action string, taskID interface{}, before, after *Task) error {
+	diff, err := json.Marshal(auditDiff(before, after))
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO audit_log (task_id, actor, action, created_at, diff) VALUES (?, ?, ?, ?, ?)`
+	start := time.Now()
+	_, err = tx.Exec(query, taskID, actor(r), action, time.Now().UTC().Format(auditTimeFormat), string(diff))
+	metrics.QueryDuration.Since(start, "insert_audit")
+	if err != nil {
+		return fmt.Errorf("ошибка при записи в журнал изменений: %w", err)
+	}
+	return nil
+}
+
+// parseAuditTime принимает дату 20060102 (в местном времени) или RFC 3339.
+// Для даты без времени to указывает на конец дня.
+func parseAuditTime(value string, end bool) (string, error) {
+	if t, err := time.ParseInLocation(dateFormat, value, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		return t.UTC().Format(auditTimeFormat), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format(auditTimeFormat), nil
+}
+
+func AuditHandler(database *db.DB) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
+			return
+		}
+
+		var where []string
+		var args []interface{}
+		params := r.URL.Query()
+
+		if idParam := params.Get("task_id"); idParam != "" {
+			id, err := strconv.Atoi(idParam)
+			if err != nil || id <= 0 {
+				http.Error(w, `{"error":"Указан некорректный идентификатор"}`, http.StatusBadRequest)
+				return
+			}
+			where, args = append(where, "task_id = ?"), append(args, id)
+		}
+		if from := params.Get("from"); from != "" {
+			t, err := parseAuditTime(from, false)
+			if err != nil {
+				http.Error(w, `{"error":"Неверный формат параметра from"}`, http.StatusBadRequest)
+				return
+			}
+			where, args = append(where, "created_at >= ?"), append(args, t)
+		}
+		if to := params.Get("to"); to != "" {
+			t, err := parseAuditTime(to, true)
+			if err != nil {
+				http.Error(w, `{"error":"Неверный формат параметра to"}`, http.StatusBadRequest)
+				return
+			}
+			where, args = append(where, "created_at <= ?"), append(args, t)
+		}
+
+		limit := 100
+		if l := params.Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n <= 0 || n > 1000 {
+				http.Error(w, `{"error":"Параметр limit должен быть от 1 до 1000"}`, http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		query := `SELECT id, task_id, actor, action, created_at, diff FROM audit_log`
+		if len(where) > 0 {
+			query += " WHERE " + strings.Join(where, " AND ")
+		}
+		query += " ORDER BY id DESC LIMIT ?"
+		args = append(args, limit)
+
+		start := time.Now()
+		rows, err := database.Query(query, args...)
+		metrics.QueryDuration.Since(start, "select_audit")
+		if err != nil {
+			http.Error(w, `{"error":"Ошибка при чтении журнала изменений"}`, http.StatusInternalServerError)
+			log.Println("Ошибка базы данных", err)
+			return
+		}
+		defer rows.Close()
+
+		type entry struct {
+			ID        string          `json:"id"`
+			TaskID    string          `json:"task_id"`
+			Actor     string          `json:"actor"`
+			Action    string          `json:"action"`
+			CreatedAt string          `json:"created_at"`
+			Diff      json.RawMessage `json:"diff"`
+		}
+		entries := []entry{}
+		for rows.Next() {
+			var e entry
+			var diff string
+			if err := rows.Scan(&e.ID, &e.TaskID, &e.Actor, &e.Action, &e.CreatedAt, &diff); err != nil {
+				http.Error(w, `{"error":"Ошибка при чтении журнала изменений"}`, http.StatusInternalServerError)
+				log.Println("Ошибка при чтении журнала изменений", err)
+				return
+			}
+			e.Diff = json.RawMessage(diff)
+			entries = append(entries, e)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, `{"error":"Ошибка при чтении журнала изменений"}`, http.StatusInternalServerError)
+			log.Println("Ошибка при чтении журнала изменений", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{"entries": entries})
+	}
+}
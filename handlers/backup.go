@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"go_final_project/backup"
+)
+
+func BackupHandler(manager *backup.Manager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var response interface{}
+		switch r.Method {
+		case http.MethodPost:
+			snapshot, err := manager.Snapshot()
+			if err != nil {
+				http.Error(w, `{"error":"Ошибка при создании резервной копии"}`, http.StatusInternalServerError)
+				log.Println("Ошибка при создании резервной копии", err)
+				return
+			}
+			response = snapshot
+		case http.MethodGet:
+			snapshots, err := backup.List(manager.Dir)
+			if err != nil {
+				http.Error(w, `{"error":"Ошибка при чтении списка резервных копий"}`, http.StatusInternalServerError)
+				log.Println("Ошибка при чтении списка резервных копий", err)
+				return
+			}
+			if snapshots == nil {
+				snapshots = []backup.Snapshot{}
+			}
+			response = map[string]interface{}{"backups": snapshots}
+		default:
+			http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
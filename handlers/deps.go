@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_final_project/db"
+	"go_final_project/metrics"
+)
+
+// taskIDs принимает идентификаторы задач и строками, и числами:
+// POST /api/task возвращает id числом, а GET - строкой.
+type taskIDs []string
+
+func (ids *taskIDs) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var raw []json.Number
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*ids = make(taskIDs, 0, len(raw))
+	for _, n := range raw {
+		*ids = append(*ids, n.String())
+	}
+	return nil
+}
+
+// dependencyError - ошибка в списке зависимостей, о которой нужно
+// сообщить клиенту (400), в отличие от ошибок базы данных.
+type dependencyError struct {
+	msg string
+}
+
+func (e dependencyError) Error() string {
+	return e.msg
+}
+
+// normalizeDeps проверяет идентификаторы, убирает повторы и сортирует их.
+func normalizeDeps(ids taskIDs) (taskIDs, error) {
+	seen := map[int64]bool{}
+	var nums []int64
+	for _, id := range ids {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, dependencyError{fmt.Sprintf("некорректный идентификатор зависимости %q", id)}
+		}
+		if !seen[n] {
+			seen[n] = true
+			nums = append(nums, n)
+		}
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+
+	result := make(taskIDs, len(nums))
+	for i, n := range nums {
+		result[i] = strconv.FormatInt(n, 10)
+	}
+	return result, nil
+}
+
+func placeholders(n int) string {
+	return "?" + strings.Repeat(", ?", n-1)
+}
+
+func idArgs(ids taskIDs) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
+
+// taskDeps возвращает незавершённые задачи, от которых зависит задача.
+// Выполненные разовые задачи удаляются, и связи уходят вместе с ними.
+func taskDeps(q db.Queryer, taskID interface{}) (taskIDs, error) {
+	start := time.Now()
+	rows, err := q.Query(`SELECT depends_on FROM task_deps WHERE task_id = ? ORDER BY depends_on`, taskID)
+	metrics.QueryDuration.Since(start, "select_task_deps")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deps taskIDs
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		deps = append(deps, id)
+	}
+	return deps, rows.Err()
+}
+
+// setTaskDeps заменяет зависимости задачи. Все задачи из deps должны
+// существовать, и ни одна из них не может сама (через другие задачи)
+// зависеть от taskID - иначе получится цикл.
+func setTaskDeps(tx *db.Tx, taskID string, deps taskIDs) error {
+	if len(deps) > 0 {
+		for _, id := range deps {
+			if id == taskID {
+				return dependencyError{"задача не может зависеть от самой себя"}
+			}
+		}
+
+		var found int
+		query := `SELECT COUNT(*) FROM scheduler WHERE id IN (` + placeholders(len(deps)) + `)`
+		if err := tx.QueryRow(query, idArgs(deps)...).Scan(&found); err != nil {
+			return err
+		}
+		if found != len(deps) {
+			return dependencyError{"задача из списка зависимостей не найдена"}
+		}
+
+		var cycles int
+		query = `WITH RECURSIVE reach(id) AS (
+			SELECT depends_on FROM task_deps WHERE task_id IN (` + placeholders(len(deps)) + `)
+			UNION
+			SELECT d.depends_on FROM task_deps d JOIN reach r ON d.task_id = r.id
+		)
+		SELECT COUNT(*) FROM reach WHERE id = ?`
+		start := time.Now()
+		err := tx.QueryRow(query, append(idArgs(deps), taskID)...).Scan(&cycles)
+		metrics.QueryDuration.Since(start, "check_task_deps")
+		if err != nil {
+			return err
+		}
+		if cycles > 0 {
+			return dependencyError{"зависимости образуют цикл"}
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM task_deps WHERE task_id = ?`, taskID); err != nil {
+		return err
+	}
+	for _, id := range deps {
+		if _, err := tx.Exec(`INSERT INTO task_deps (task_id, depends_on) VALUES (?, ?)`, taskID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDependencyError(err error) bool {
+	var depErr dependencyError
+	return errors.As(err, &depErr)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go_final_project/events"
+	"go_final_project/webhook"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+// taskChanged сообщает об изменении задачи после фиксации транзакции:
+// будит отправку вебхуков и публикует событие для /api/events.
+func taskChanged(r *http.Request, event string, task *Task) {
+	webhook.Wake()
+	origin, _ := r.Context().Value(originKey{}).(string)
+	events.Publish(event, actor(r), origin, task)
+}
+
+// EventsHandler отдаёт изменения задач потоком Server-Sent Events.
+// Пропущенные при обрыве события досылаются по Last-Event-ID (заголовок
+// или параметр last_event_id); если их уже нет в истории, первым приходит
+// событие reset - клиенту нужно перечитать список задач.
+func EventsHandler(hub *events.Hub) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
+			return
+		}
+
+		lastParam := r.Header.Get("Last-Event-ID")
+		if lastParam == "" {
+			lastParam = r.URL.Query().Get("last_event_id")
+		}
+		var lastID int64
+		if lastParam != "" {
+			n, err := strconv.ParseInt(lastParam, 10, 64)
+			if err != nil || n < 0 {
+				http.Error(w, `{"error":"Некорректный Last-Event-ID"}`, http.StatusBadRequest)
+				return
+			}
+			lastID = n
+		}
+
+		// Поток живёт дольше write_timeout сервера.
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+			http.Error(w, `{"error":"Ошибка при открытии потока"}`, http.StatusInternalServerError)
+			return
+		}
+
+		// Пользователей в сервере пока нет, поэтому все видят все события;
+		// с авторизацией сюда добавится фильтр по владельцу задачи.
+		sub := hub.Subscribe(lastID, nil)
+		defer hub.Unsubscribe(sub)
+
+		w.Header().Set("Content-Type", "text/event-stream; charset=UTF-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 3000\n\n")
+		if sub.Reset {
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case e, ok := <-sub.Events:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(e)
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -7,38 +7,61 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
+
+	"go_final_project/db"
+	"go_final_project/metrics"
+	"go_final_project/webhook"
 )
 
-var task struct {
-	ID      string `json:"id"`
-	Date    string `json:"date"`
-	Title   string `json:"title"`
-	Comment string `json:"comment"`
-	Repeat  string `json:"repeat"`
+type Task struct {
+	ID        string     `json:"id"`
+	Date      string     `json:"date"`
+	Title     string     `json:"title"`
+	Comment   string     `json:"comment"`
+	Repeat    string     `json:"repeat"`
+	Priority  string     `json:"priority"`
+	Tags      []string   `json:"tags,omitempty"`
+	Items     []TaskItem `json:"items,omitempty"`
+	BlockedBy taskIDs    `json:"blocked_by,omitempty"`
+	Version   string     `json:"version,omitempty"`
 }
 
 const dateFormat = "20060102"
 
-func TaskHandler(database *sql.DB) func(w http.ResponseWriter, r *http.Request) {
+const defaultPriority = "normal"
+
+// priorities - допустимые приоритеты по возрастанию важности.
+var priorities = []string{"low", "normal", "high", "urgent"}
+
+func validPriority(priority string) bool {
+	for _, p := range priorities {
+		if p == priority {
+			return true
+		}
+	}
+	return false
+}
+
+// requireIfMatch - отклонять изменения без заголовка If-Match (428).
+func TaskHandler(database *db.DB, requireIfMatch bool) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPut:
-			updateTaskHandler(database, w, r)
+			updateTaskHandler(database, requireIfMatch, w, r)
 		case http.MethodGet:
 			getTaskHandler(database, w, r)
 		case http.MethodPost:
 			createTaskHandler(database, w, r)
 		case http.MethodDelete:
-			deleteTaskHandler(database, w, r)
+			deleteTaskHandler(database, requireIfMatch, w, r)
 		default:
 			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		}
 	}
 }
 
-func deleteTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request) {
+func deleteTaskHandler(database *db.DB, requireIfMatch bool, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
 		return
@@ -57,7 +80,38 @@ func deleteTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	res, err := database.Exec("DELETE FROM scheduler WHERE id=?", id)
+	version, err := ifMatch(r, requireIfMatch)
+	if err != nil {
+		writeIfMatchError(w, err)
+		return
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при открытии транзакции: ", err)
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := selectTask(tx, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, `{"error":"Ошибка при извлечении задачи из базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных: ", err)
+		return
+	}
+	if !versionMatches(version, before) {
+		tx.Rollback()
+		writePreconditionFailed(w, before)
+		return
+	}
+
+	start := time.Now()
+	res, err := tx.Exec("DELETE FROM scheduler WHERE id=? AND version=?", id, before.Version)
+	metrics.QueryDuration.Since(start, "delete_task")
 	if err != nil {
 		http.Error(w, `{"error": "Ошибка при удалении задачи"}`, http.StatusInternalServerError)
 		log.Println("Ошибка при удалении задачи: ", err)
@@ -70,15 +124,34 @@ func deleteTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if cnt == 0 {
-		http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
+		tx.Rollback()
+		writeConflict(database, w, id)
+		return
+	}
+
+	if err := audit(tx, r, "delete", id, &before, nil); err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	if err := webhook.Enqueue(tx, webhook.TaskDeleted, &before); err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, `{"error": "Ошибка при удалении задачи"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при удалении задачи: ", err)
+		return
 	}
+	taskChanged(r, webhook.TaskDeleted, &before)
 
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{})
 }
 
-func MarkTaskDoneHandler(database *sql.DB) func(w http.ResponseWriter, r *http.Request) {
+func MarkTaskDoneHandler(database *db.DB, requireIfMatch bool) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
@@ -98,8 +171,21 @@ func MarkTaskDoneHandler(database *sql.DB) func(w http.ResponseWriter, r *http.R
 			return
 		}
 
-		query := `SELECT id, date, repeat FROM scheduler WHERE id = ?`
-		err = database.QueryRow(query, id).Scan(&task.ID, &task.Date, &task.Repeat)
+		version, err := ifMatch(r, requireIfMatch)
+		if err != nil {
+			writeIfMatchError(w, err)
+			return
+		}
+
+		tx, err := database.Begin()
+		if err != nil {
+			http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+			log.Println("Ошибка при открытии транзакции: ", err)
+			return
+		}
+		defer tx.Rollback()
+
+		task, err := selectTask(tx, id)
 		if err == sql.ErrNoRows {
 			http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
 			log.Println("Задача не найдена: ", err)
@@ -109,9 +195,31 @@ func MarkTaskDoneHandler(database *sql.DB) func(w http.ResponseWriter, r *http.R
 			log.Println("Ошибка базы данных: ", err)
 			return
 		}
+		if !versionMatches(version, task) {
+			tx.Rollback()
+			writePreconditionFailed(w, task)
+			return
+		}
 
+		force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+		if len(task.BlockedBy) > 0 && !force {
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":      "Задача ждёт выполнения других задач",
+				"blocked_by": task.BlockedBy,
+			})
+			return
+		}
+
+		// Проверяем версию ещё раз в самом запросе: в PostgreSQL задачу могли
+		// изменить между чтением и записью.
+		var res sql.Result
+		var after *Task
 		if task.Repeat == "" {
-			_, err = database.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+			start := time.Now()
+			res, err = tx.Exec(`DELETE FROM scheduler WHERE id = ? AND version = ?`, id, task.Version)
+			metrics.QueryDuration.Since(start, "delete_task")
 			if err != nil {
 				http.Error(w, `{"error":"Ошибка при удалении задачи"}`, http.StatusInternalServerError)
 				log.Println("Ошибка при удалении задачи: ", err)
@@ -126,23 +234,78 @@ func MarkTaskDoneHandler(database *sql.DB) func(w http.ResponseWriter, r *http.R
 				return
 			}
 
-			_, err = database.Exec(`UPDATE scheduler SET date = ? WHERE id = ?`, nextDate, id)
+			start := time.Now()
+			res, err = tx.Exec(`UPDATE scheduler SET date = ?, version = version + 1 WHERE id = ? AND version = ?`, nextDate, id, task.Version)
+			metrics.QueryDuration.Since(start, "update_task_date")
 			if err != nil {
 				http.Error(w, `{"error":"Ошибка при обновлении задачи"}`, http.StatusInternalServerError)
 				log.Println("Ошибка при обновлении задачи", err)
 				return
 			}
+			// В новом повторе пункты чек-листа снова не отмечены.
+			start = time.Now()
+			_, err = tx.Exec(`UPDATE task_items SET done = 0 WHERE task_id = ?`, id)
+			metrics.QueryDuration.Since(start, "reset_task_items")
+			if err != nil {
+				http.Error(w, `{"error":"Ошибка при обновлении задачи"}`, http.StatusInternalServerError)
+				log.Println("Ошибка при сбросе чек-листа", err)
+				return
+			}
+			next := task
+			next.Date = nextDate
+			next.Items = uncheckedItems(task.Items)
+			after = &next
+		}
+		if cnt, err := res.RowsAffected(); err == nil && cnt == 0 {
+			tx.Rollback()
+			writeConflict(database, w, id)
+			return
 		}
 
+		// Задачи, которые ждали эту, больше не заблокированы. У разовой задачи
+		// связи удалились вместе с ней, у повторяющейся удаляем их сами.
+		start := time.Now()
+		_, err = tx.Exec(`DELETE FROM task_deps WHERE depends_on = ?`, id)
+		metrics.QueryDuration.Since(start, "delete_task_deps")
+		if err != nil {
+			http.Error(w, `{"error":"Ошибка при обновлении задачи"}`, http.StatusInternalServerError)
+			log.Println("Ошибка при удалении зависимостей", err)
+			return
+		}
+
+		if err := audit(tx, r, "done", id, &task, after); err != nil {
+			http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+		if err := webhook.Enqueue(tx, webhook.TaskDone, &task); err != nil {
+			http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error":"Ошибка при обновлении задачи"}`, http.StatusInternalServerError)
+			log.Println("Ошибка при обновлении задачи", err)
+			return
+		}
+		taskChanged(r, webhook.TaskDone, &task)
+
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{}`))
 	}
 }
 
-func updateTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request) {
+func updateTaskHandler(database *db.DB, requireIfMatch bool, w http.ResponseWriter, r *http.Request) {
+	version, err := ifMatch(r, requireIfMatch)
+	if err != nil {
+		writeIfMatchError(w, err)
+		return
+	}
+
+	var task Task
 	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&task)
+	err = decoder.Decode(&task)
 	if err != nil {
 		http.Error(w, `{"error":"Неверный формат данных"}`, http.StatusBadRequest)
 		log.Println("Неверный формат данных", err)
@@ -159,6 +322,29 @@ func updateTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if task.Priority != "" && !validPriority(task.Priority) {
+		http.Error(w, `{"error":"Неизвестный приоритет"}`, http.StatusBadRequest)
+		return
+	}
+
+	// tags == nil - поле не передано, теги остаются прежними.
+	if task.Tags != nil {
+		task.Tags, err = normalizeTags(task.Tags)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// То же для зависимостей.
+	if task.BlockedBy != nil {
+		task.BlockedBy, err = normalizeDeps(task.BlockedBy)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
 	var taskDate time.Time
 	if task.Date == "" {
 		taskDate = time.Now()
@@ -195,8 +381,15 @@ func updateTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	var existingID int
-	err = database.QueryRow("SELECT id FROM scheduler WHERE id = ?", task.ID).Scan(&existingID)
+	tx, err := database.Begin()
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при открытии транзакции", err)
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := selectTask(tx, task.ID)
 	if err == sql.ErrNoRows {
 		http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
 		log.Println("Задача не найдена", err)
@@ -206,28 +399,93 @@ func updateTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request)
 		log.Println("Ошибка при проверке задачи", err)
 		return
 	}
+	if !versionMatches(version, before) {
+		tx.Rollback()
+		writePreconditionFailed(w, before)
+		return
+	}
 
-	query := `UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?`
-	_, err = database.Exec(query, taskDate.Format("20060102"), task.Title, task.Comment, task.Repeat, task.ID)
-	if err != nil {
+	task.ID = before.ID
+	task.Date = taskDate.Format(dateFormat)
+	// Чек-лист меняется через /api/task/items, PUT его не трогает.
+	task.Items = before.Items
+	// Клиенты, которые не знают о приоритетах, не должны его сбрасывать.
+	if task.Priority == "" {
+		task.Priority = before.Priority
+	}
+	query := `UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ?, priority = ?, version = version + 1
+		WHERE id = ? AND version = ? RETURNING version`
+	start := time.Now()
+	err = tx.QueryRow(query, task.Date, task.Title, task.Comment, task.Repeat, task.Priority, task.ID, before.Version).Scan(&task.Version)
+	metrics.QueryDuration.Since(start, "update_task")
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		writeConflict(database, w, task.ID)
+		return
+	} else if err != nil {
 		http.Error(w, `{"error":"Ошибка при обновлении задачи"}`, http.StatusInternalServerError)
 		log.Println("Ошибка при обновлении задачи", err)
 		return
 	}
 
+	if task.Tags == nil {
+		task.Tags = before.Tags
+	} else if err := setTaskTags(tx, task.ID, task.Tags); err != nil {
+		http.Error(w, `{"error":"Ошибка при обновлении тегов"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при обновлении тегов", err)
+		return
+	}
+
+	if task.BlockedBy == nil {
+		task.BlockedBy = before.BlockedBy
+	} else if err := setTaskDeps(tx, task.ID, task.BlockedBy); isDependencyError(err) {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, `{"error":"Ошибка при обновлении зависимостей"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при обновлении зависимостей", err)
+		return
+	}
+
+	if err := audit(tx, r, "update", task.ID, &before, &task); err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	if err := webhook.Enqueue(tx, webhook.TaskUpdated, &task); err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, `{"error":"Ошибка при обновлении задачи"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при обновлении задачи", err)
+		return
+	}
+	taskChanged(r, webhook.TaskUpdated, &task)
+
 	response := map[string]interface{}{
-		"id":      task.ID,
-		"date":    taskDate.Format(dateFormat),
-		"title":   task.Title,
-		"comment": task.Comment,
-		"repeat":  task.Repeat,
+		"id":       task.ID,
+		"date":     taskDate.Format(dateFormat),
+		"title":    task.Title,
+		"comment":  task.Comment,
+		"repeat":   task.Repeat,
+		"priority": task.Priority,
+		"version":  task.Version,
+	}
+	if len(task.Tags) > 0 {
+		response["tags"] = task.Tags
+	}
+	if len(task.BlockedBy) > 0 {
+		response["blocked_by"] = task.BlockedBy
 	}
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("ETag", etag(task.Version))
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
-func getTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request) {
+func getTaskHandler(database *db.DB, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
@@ -249,9 +507,7 @@ func getTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?`
-
-	err = database.QueryRow(query, id).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	task, err := selectTask(database, id)
 	if err == sql.ErrNoRows {
 		http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
 		log.Println("Задача не найдена", err)
@@ -263,6 +519,7 @@ func getTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("ETag", etag(task.Version))
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(task)
 	if err != nil {
@@ -271,45 +528,111 @@ func getTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func GetTasksHandler(database *sql.DB) func(w http.ResponseWriter, r *http.Request) {
+// taskSorts - выражения ORDER BY для параметра sort списка задач.
+// Отдельного времени создания нет: id растёт с каждой новой задачей.
+var taskSorts = map[string]string{
+	"date":     "date",
+	"title":    "title",
+	"created":  "id",
+	"priority": "CASE priority WHEN 'low' THEN 0 WHEN 'normal' THEN 1 WHEN 'high' THEN 2 ELSE 3 END",
+}
+
+func GetTasksHandler(database *db.DB) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
 			return
 		}
 
-		query := `SELECT id, date, title, comment, repeat FROM scheduler WHERE date >= ? ORDER BY date LIMIT 50`
-		now := time.Now().Format("20060102")
-		rows, err := database.Query(query, now)
+		sort := r.URL.Query().Get("sort")
+		if sort == "" {
+			sort = "date"
+		}
+		column, ok := taskSorts[sort]
+		if !ok {
+			http.Error(w, `{"error":"Неизвестный параметр sort"}`, http.StatusBadRequest)
+			return
+		}
+		order := r.URL.Query().Get("order")
+		switch order {
+		case "":
+			// Важные задачи удобнее видеть первыми.
+			order = "asc"
+			if sort == "priority" {
+				order = "desc"
+			}
+		case "asc", "desc":
+		default:
+			http.Error(w, `{"error":"Параметр order должен быть asc или desc"}`, http.StatusBadRequest)
+			return
+		}
+
+		where := "date >= ?"
+		args := []interface{}{time.Now().Format("20060102")}
+		if tags := r.URL.Query()["tag"]; len(tags) > 0 {
+			tags, err := normalizeTags(tags)
+			if err != nil {
+				http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+				return
+			}
+			filter, filterArgs := tagFilter(tags)
+			where, args = where+" AND "+filter, append(args, filterArgs...)
+		}
+
+		query := `SELECT id, date, title, comment, repeat, priority,
+			EXISTS (SELECT 1 FROM task_deps d WHERE d.task_id = scheduler.id)
+			FROM scheduler WHERE ` + where + `
+			ORDER BY ` + column + ` ` + order + `, date, id LIMIT 50`
+		start := time.Now()
+		rows, err := database.Query(query, args...)
+		metrics.QueryDuration.Since(start, "select_tasks")
 		if err != nil {
 			http.Error(w, `{"error":"Ошибка при извлечении задач из базы данных"}`, http.StatusInternalServerError)
 			log.Println("Ошибка базы данных", err)
 			return
 		}
+		defer rows.Close()
 
-		var tasks []map[string]string
+		var ids []string
+		tasks := []map[string]interface{}{}
 		for rows.Next() {
 			var id int
-			var date, title, comment, repeat string
-			err := rows.Scan(&id, &date, &title, &comment, &repeat)
+			var date, title, comment, repeat, priority string
+			var blocked bool
+			err := rows.Scan(&id, &date, &title, &comment, &repeat, &priority, &blocked)
 			if err != nil {
 				http.Error(w, `{"error":"Ошибка при чтении данных задачи"}`, http.StatusInternalServerError)
 				log.Println("Ошибка при чтении данных задачи", err)
 				return
 			}
 
-			task := map[string]string{
-				"id":      strconv.Itoa(id),
-				"date":    date,
-				"title":   title,
-				"comment": comment,
-				"repeat":  repeat,
+			ids = append(ids, strconv.Itoa(id))
+			task := map[string]interface{}{
+				"id":       strconv.Itoa(id),
+				"date":     date,
+				"title":    title,
+				"comment":  comment,
+				"repeat":   repeat,
+				"priority": priority,
+			}
+			if blocked {
+				task["blocked"] = true
 			}
 			tasks = append(tasks, task)
 		}
+		// Соединение одно: строки нужно дочитать и закрыть до следующего запроса.
+		rows.Close()
 
-		if len(tasks) == 0 {
-			tasks = []map[string]string{}
+		tags, err := tagsByTask(database, ids)
+		if err != nil {
+			http.Error(w, `{"error":"Ошибка при извлечении тегов из базы данных"}`, http.StatusInternalServerError)
+			log.Println("Ошибка базы данных", err)
+			return
+		}
+		for i, id := range ids {
+			if len(tags[id]) > 0 {
+				tasks[i]["tags"] = tags[id]
+			}
 		}
 
 		response := map[string]interface{}{
@@ -326,17 +649,23 @@ func GetTasksHandler(database *sql.DB) func(w http.ResponseWriter, r *http.Reque
 	}
 }
 
-func createTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request) {
+func createTaskHandler(database *db.DB, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var newTask struct {
-		Date    string `json:"date"`
-		Title   string `json:"title"`
-		Comment string `json:"comment"`
-		Repeat  string `json:"repeat"`
+		Date      string   `json:"date"`
+		Title     string   `json:"title"`
+		Comment   string   `json:"comment"`
+		Repeat    string   `json:"repeat"`
+		Priority  string   `json:"priority"`
+		Tags      []string `json:"tags"`
+		BlockedBy taskIDs  `json:"blocked_by"`
+		// When - срок словами ("завтра", "every monday"), см. ParseWhen.
+		// Дополняет date и repeat, если они не указаны явно.
+		When string `json:"when"`
 	}
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&newTask)
@@ -351,6 +680,41 @@ func createTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if newTask.Priority == "" {
+		newTask.Priority = defaultPriority
+	} else if !validPriority(newTask.Priority) {
+		http.Error(w, `{"error":"Неизвестный приоритет"}`, http.StatusBadRequest)
+		return
+	}
+
+	newTask.Tags, err = normalizeTags(newTask.Tags)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	newTask.BlockedBy, err = normalizeDeps(newTask.BlockedBy)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if newTask.When != "" {
+		date, repeat, err := ParseWhen(time.Now(), newTask.When)
+		if err != nil {
+			// В ошибке цитируется текст пользователя, его нужно экранировать.
+			body, _ := json.Marshal(map[string]string{"error": err.Error()})
+			http.Error(w, string(body), http.StatusBadRequest)
+			return
+		}
+		if newTask.Date == "" {
+			newTask.Date = date
+		}
+		if newTask.Repeat == "" {
+			newTask.Repeat = repeat
+		}
+	}
+
 	var taskDate time.Time
 	if newTask.Date == "" {
 		taskDate = time.Now()
@@ -386,29 +750,82 @@ func createTaskHandler(database *sql.DB, w http.ResponseWriter, r *http.Request)
 	} else if taskDate.Format(dateFormat) == now.Format(dateFormat) {
 	}
 
-	query := `INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`
-	res, err := database.Exec(query, taskDate.Format(dateFormat), newTask.Title, newTask.Comment, newTask.Repeat)
+	tx, err := database.Begin()
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при открытии транзакции", err)
+		return
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO scheduler (date, title, comment, repeat, priority) VALUES (?, ?, ?, ?, ?)`
+	start := time.Now()
+	id, err := tx.Insert(query, taskDate.Format(dateFormat), newTask.Title, newTask.Comment, newTask.Repeat, newTask.Priority)
+	metrics.QueryDuration.Since(start, "insert_task")
 	if err != nil {
 		http.Error(w, `{"error":"Ошибка при добавлении задачи в базу данных"}`, http.StatusInternalServerError)
 		log.Println("Ошибка базы данных", err)
 		return
 	}
 
-	id, err := res.LastInsertId()
-	if err != nil {
-		http.Error(w, `{"error":"Не удалось получить id задачи"}`, http.StatusInternalServerError)
-		log.Println("Не удалось получить id задачи", err)
+	created := Task{
+		ID:        strconv.FormatInt(id, 10),
+		Date:      taskDate.Format(dateFormat),
+		Title:     newTask.Title,
+		Comment:   newTask.Comment,
+		Repeat:    newTask.Repeat,
+		Priority:  newTask.Priority,
+		Tags:      newTask.Tags,
+		BlockedBy: newTask.BlockedBy,
+		Version:   "1",
+	}
+	if err := setTaskTags(tx, id, newTask.Tags); err != nil {
+		http.Error(w, `{"error":"Ошибка при добавлении тегов"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при добавлении тегов", err)
+		return
+	}
+	if err := setTaskDeps(tx, created.ID, newTask.BlockedBy); isDependencyError(err) {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, `{"error":"Ошибка при добавлении зависимостей"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при добавлении зависимостей", err)
+		return
+	}
+	if err := audit(tx, r, "create", id, nil, &created); err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	if err := webhook.Enqueue(tx, webhook.TaskCreated, &created); err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println(err)
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, `{"error":"Ошибка при добавлении задачи в базу данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	taskChanged(r, webhook.TaskCreated, &created)
 
 	response := map[string]interface{}{
-		"id":      id,
-		"date":    taskDate.Format(dateFormat),
-		"title":   newTask.Title,
-		"comment": newTask.Comment,
-		"repeat":  newTask.Repeat,
+		"id":       id,
+		"date":     taskDate.Format(dateFormat),
+		"title":    newTask.Title,
+		"comment":  newTask.Comment,
+		"repeat":   newTask.Repeat,
+		"priority": newTask.Priority,
+		"version":  "1",
+	}
+	if len(newTask.Tags) > 0 {
+		response["tags"] = newTask.Tags
+	}
+	if len(newTask.BlockedBy) > 0 {
+		response["blocked_by"] = newTask.BlockedBy
 	}
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("ETag", etag("1"))
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
@@ -443,6 +860,14 @@ func NextDateHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func NextDate(now time.Time, date string, repeat string) (string, error) {
+	next, err := nextDate(now, date, repeat)
+	if err != nil {
+		metrics.NextDateErrors.Inc()
+	}
+	return next, err
+}
+
+func nextDate(now time.Time, date string, repeat string) (string, error) {
 	now = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 
 	taskDate, err := time.Parse(dateFormat, date)
@@ -451,41 +876,9 @@ func NextDate(now time.Time, date string, repeat string) (string, error) {
 		return "", fmt.Errorf("неверный формат даты")
 	}
 
-	parts := strings.Fields(repeat)
-	if len(parts) == 0 {
-		log.Println("Пустое правило повторения", err)
-		return "", fmt.Errorf("пустое правило повторения")
-	}
-
-	switch parts[0] {
-	case "d":
-		if len(parts) != 2 {
-			return "", fmt.Errorf("неверный формат repeat")
-		}
-		days, err := strconv.Atoi(parts[1])
-		if err != nil || days <= 0 || days > 400 {
-			log.Println("Неверное соблюдение правил", err)
-			return "", fmt.Errorf("неверное количество дней в repeat")
-		}
-
-		taskDate = taskDate.AddDate(0, 0, days)
-
-		for taskDate.Format(dateFormat) <= now.Format(dateFormat) {
-			taskDate = taskDate.AddDate(0, 0, days)
-		}
-
-		return taskDate.Format(dateFormat), nil
-
-	case "y":
-		taskDate = taskDate.AddDate(1, 0, 0)
-
-		for taskDate.Format(dateFormat) <= now.Format(dateFormat) {
-			taskDate = taskDate.AddDate(1, 0, 0)
-		}
-
-		return taskDate.Format(dateFormat), nil
-
-	default:
-		return "", fmt.Errorf("неизвестный формат repeat")
+	rule, err := parseRepeat(repeat)
+	if err != nil {
+		return "", err
 	}
+	return rule.next(now, taskDate)
 }
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go_final_project/db"
+)
+
+var shuttingDown atomic.Bool
+
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func ReadyHandler(database *db.DB, webDir string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		components := map[string]string{}
+		ready := true
+
+		check := func(name string, err error) {
+			if err != nil {
+				components[name] = err.Error()
+				ready = false
+				return
+			}
+			components[name] = "ok"
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		check("database", database.PingContext(ctx))
+		check("migrations", db.CheckSchema(database))
+
+		info, err := os.Stat(webDir)
+		if err == nil && !info.IsDir() {
+			err = fmt.Errorf("%s не является директорией", webDir)
+		}
+		check("web", err)
+
+		if shuttingDown.Load() {
+			components["server"] = "завершение работы"
+			ready = false
+		} else {
+			components["server"] = "ok"
+		}
+
+		status, code := "ready", http.StatusOK
+		if !ready {
+			status, code = "not ready", http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     status,
+			"components": components,
+		})
+	}
+}
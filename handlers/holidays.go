@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go_final_project/calendar"
+	"go_final_project/db"
+)
+
+// maxCalendarSize ограничивает файл календаря в /api/holidays/import.
+const maxCalendarSize = 1 << 20
+
+// reloadCalendar обновляет календарь в памяти после изменения таблицы.
+func reloadCalendar(database *db.DB) {
+	if err := calendar.Default.Load(database); err != nil {
+		log.Println("Ошибка при загрузке производственного календаря", err)
+	}
+}
+
+// yearRange возвращает границы года для параметра year.
+func yearRange(year string) (string, string, bool) {
+	if n, err := strconv.Atoi(year); err != nil || n < 1 || n > 9999 || len(year) != 4 {
+		return "", "", false
+	}
+	return year + "0101", year + "1231", true
+}
+
+// HolidaysHandler управляет производственным календарём: GET ?year= или
+// ?from=&to= - список дней, POST {"date","kind","title"} - добавить или
+// изменить день, DELETE ?date= или ?year= - удалить.
+func HolidaysHandler(database *db.DB) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listHolidays(database, w, r)
+		case http.MethodPost:
+			saveHoliday(database, w, r)
+		case http.MethodDelete:
+			deleteHolidays(database, w, r)
+		default:
+			http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func listHolidays(database *db.DB, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to := q.Get("from"), q.Get("to")
+	if year := q.Get("year"); year != "" {
+		var ok bool
+		if from, to, ok = yearRange(year); !ok {
+			http.Error(w, `{"error":"Неверный год"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	for _, d := range []string{from, to} {
+		if _, err := time.Parse(dateFormat, d); d != "" && err != nil {
+			http.Error(w, `{"error":"Неверный формат даты"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	days, err := calendar.List(database, from, to)
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при чтении календаря"}`, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"holidays": days})
+}
+
+func saveHoliday(database *db.DB, w http.ResponseWriter, r *http.Request) {
+	var day calendar.Day
+	if err := json.NewDecoder(r.Body).Decode(&day); err != nil {
+		http.Error(w, `{"error":"Неверный формат данных"}`, http.StatusBadRequest)
+		return
+	}
+	if err := day.Validate(); err != nil {
+		body, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(body), http.StatusBadRequest)
+		return
+	}
+	if err := calendar.Save(database, []calendar.Day{day}); err != nil {
+		http.Error(w, `{"error":"Ошибка при записи календаря"}`, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	reloadCalendar(database)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(day)
+}
+
+func deleteHolidays(database *db.DB, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to := q.Get("date"), q.Get("date")
+	if year := q.Get("year"); year != "" {
+		var ok bool
+		if from, to, ok = yearRange(year); !ok {
+			http.Error(w, `{"error":"Неверный год"}`, http.StatusBadRequest)
+			return
+		}
+	} else if _, err := time.Parse(dateFormat, from); err != nil {
+		http.Error(w, `{"error":"Укажите date или year"}`, http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := calendar.Delete(database, from, to)
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при удалении дней календаря"}`, http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	if q.Get("year") == "" && deleted == 0 {
+		http.Error(w, `{"error":"Дня нет в календаре"}`, http.StatusNotFound)
+		return
+	}
+	reloadCalendar(database)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if q.Get("year") != "" {
+		json.NewEncoder(w).Encode(map[string]int64{"deleted": deleted})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{})
+}
+
+// HolidaysImportHandler загружает файл календаря из тела POST-запроса
+// (см. calendar.Read). С ?replace=true дни тех же лет, которых нет
+// в файле, удаляются.
+func HolidaysImportHandler(database *db.DB) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
+			return
+		}
+
+		days, err := calendar.Read(http.MaxBytesReader(w, r.Body, maxCalendarSize))
+		if err != nil {
+			body, _ := json.Marshal(map[string]string{"error": err.Error()})
+			http.Error(w, string(body), http.StatusBadRequest)
+			return
+		}
+		if len(days) == 0 {
+			http.Error(w, `{"error":"В файле нет дней календаря"}`, http.StatusBadRequest)
+			return
+		}
+		replace, _ := strconv.ParseBool(r.URL.Query().Get("replace"))
+
+		if err := calendar.Import(database, days, replace); err != nil {
+			http.Error(w, `{"error":"Ошибка при записи календаря"}`, http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+		reloadCalendar(database)
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]int{"imported": len(days)})
+	}
+}
@@ -0,0 +1,475 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go_final_project/db"
+	"go_final_project/metrics"
+	"go_final_project/webhook"
+)
+
+const maxItemTitleLength = 256
+
+// TaskItem - пункт чек-листа задачи. Пункты нумеруются с 1 без пропусков.
+type TaskItem struct {
+	ID       string `json:"id"`
+	TaskID   string `json:"task_id"`
+	Position int    `json:"position"`
+	Title    string `json:"title"`
+	Done     bool   `json:"done"`
+}
+
+func taskItems(q db.Queryer, taskID interface{}) ([]TaskItem, error) {
+	query := `SELECT id, task_id, position, title, done FROM task_items WHERE task_id = ? ORDER BY position, id`
+	start := time.Now()
+	rows, err := q.Query(query, taskID)
+	metrics.QueryDuration.Since(start, "select_task_items")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []TaskItem
+	for rows.Next() {
+		var item TaskItem
+		if err := rows.Scan(&item.ID, &item.TaskID, &item.Position, &item.Title, &item.Done); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func selectItem(q db.Queryer, id int) (TaskItem, error) {
+	var item TaskItem
+	query := `SELECT id, task_id, position, title, done FROM task_items WHERE id = ?`
+	start := time.Now()
+	err := q.QueryRow(query, id).Scan(&item.ID, &item.TaskID, &item.Position, &item.Title, &item.Done)
+	metrics.QueryDuration.Since(start, "select_task_item")
+	return item, err
+}
+
+func countItems(q db.Queryer, taskID interface{}) (int, error) {
+	var n int
+	err := q.QueryRow(`SELECT COUNT(*) FROM task_items WHERE task_id = ?`, taskID).Scan(&n)
+	return n, err
+}
+
+// itemsChanged отражает изменение чек-листа на задаче так же, как правку
+// задачи: чек-лист входит в задачу, поэтому растёт её версия (и ETag),
+// в журнал пишется разница, а подписчики получают task.updated. before -
+// задача, прочитанная в той же транзакции до изменения. Если версию успели
+// поменять, возвращает sql.ErrNoRows. Транзакцию фиксирует вызывающий,
+// после успешного ответа он же вызывает taskChanged.
+func itemsChanged(tx *db.Tx, r *http.Request, before Task) (Task, error) {
+	var version string
+	err := tx.QueryRow(`UPDATE scheduler SET version = version + 1 WHERE id = ? AND version = ? RETURNING version`,
+		before.ID, before.Version).Scan(&version)
+	if err != nil {
+		return before, err
+	}
+	after, err := selectTask(tx, before.ID)
+	if err != nil {
+		return before, err
+	}
+	if err := audit(tx, r, "update", before.ID, &before, &after); err != nil {
+		return before, err
+	}
+	if err := webhook.Enqueue(tx, webhook.TaskUpdated, &after); err != nil {
+		return before, err
+	}
+	return after, nil
+}
+
+// uncheckedItems возвращает копию чек-листа без отметок.
+func uncheckedItems(items []TaskItem) []TaskItem {
+	if items == nil {
+		return nil
+	}
+	result := make([]TaskItem, len(items))
+	for i, item := range items {
+		item.Done = false
+		result[i] = item
+	}
+	return result
+}
+
+func validItemTitle(title string) bool {
+	title = strings.TrimSpace(title)
+	return title != "" && utf8.RuneCountInString(title) <= maxItemTitleLength
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// TaskItemsHandler - чек-лист задачи. Изменения проверяют If-Match по версии
+// задачи так же, как TaskHandler.
+func TaskItemsHandler(database *db.DB, requireIfMatch bool) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listItemsHandler(database, w, r)
+		case http.MethodPost:
+			createItemHandler(database, w, r, requireIfMatch)
+		case http.MethodPut:
+			updateItemHandler(database, w, r, requireIfMatch)
+		case http.MethodDelete:
+			deleteItemHandler(database, w, r, requireIfMatch)
+		default:
+			http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// queryID разбирает положительный идентификатор из параметра запроса
+// и сам отвечает ошибкой, если его нет или он некорректный.
+func queryID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	param := r.URL.Query().Get(name)
+	if param == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return 0, false
+	}
+	id, err := strconv.Atoi(param)
+	if err != nil || id <= 0 {
+		http.Error(w, `{"error":"Указан некорректный идентификатор"}`, http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeItem отвечает пунктом, а в ETag - новой версией задачи.
+func writeItem(w http.ResponseWriter, item TaskItem, task Task) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("ETag", etag(task.Version))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(item)
+}
+
+func listItemsHandler(database *db.DB, w http.ResponseWriter, r *http.Request) {
+	taskID, ok := queryID(w, r, "task_id")
+	if !ok {
+		return
+	}
+
+	if _, err := selectTask(database, taskID); err == sql.ErrNoRows {
+		http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, `{"error":"Ошибка при извлечении задачи из базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+
+	items, err := taskItems(database, taskID)
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при извлечении чек-листа"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	if items == nil {
+		items = []TaskItem{}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+}
+
+func createItemHandler(database *db.DB, w http.ResponseWriter, r *http.Request, requireIfMatch bool) {
+	taskID, ok := queryID(w, r, "task_id")
+	if !ok {
+		return
+	}
+	version, err := ifMatch(r, requireIfMatch)
+	if err != nil {
+		writeIfMatchError(w, err)
+		return
+	}
+
+	var body struct {
+		Title    string `json:"title"`
+		Position int    `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, `{"error":"Неверный формат данных"}`, http.StatusBadRequest)
+		log.Println("Неверный формат данных", err)
+		return
+	}
+	if !validItemTitle(body.Title) {
+		http.Error(w, `{"error":"Не указан или слишком длинный текст пункта"}`, http.StatusBadRequest)
+		return
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при открытии транзакции", err)
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := selectTask(tx, taskID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	if !versionMatches(version, before) {
+		tx.Rollback()
+		writePreconditionFailed(w, before)
+		return
+	}
+
+	n, err := countItems(tx, taskID)
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	if body.Position == 0 {
+		body.Position = n + 1
+	}
+	if body.Position < 1 || body.Position > n+1 {
+		http.Error(w, `{"error":"Неверная позиция пункта"}`, http.StatusBadRequest)
+		return
+	}
+
+	item := TaskItem{
+		TaskID:   strconv.Itoa(taskID),
+		Position: body.Position,
+		Title:    strings.TrimSpace(body.Title),
+	}
+	start := time.Now()
+	_, err = tx.Exec(`UPDATE task_items SET position = position + 1 WHERE task_id = ? AND position >= ?`, taskID, item.Position)
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при добавлении пункта"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при добавлении пункта", err)
+		return
+	}
+	id, err := tx.Insert(`INSERT INTO task_items (task_id, position, title, done) VALUES (?, ?, ?, 0)`,
+		taskID, item.Position, item.Title)
+	metrics.QueryDuration.Since(start, "insert_task_item")
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при добавлении пункта"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при добавлении пункта", err)
+		return
+	}
+	after, err := itemsChanged(tx, r, before)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		writeConflict(database, w, taskID)
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при добавлении пункта"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при добавлении пункта", err)
+		return
+	}
+	taskChanged(r, webhook.TaskUpdated, &after)
+
+	item.ID = strconv.FormatInt(id, 10)
+	writeItem(w, item, after)
+}
+
+func updateItemHandler(database *db.DB, w http.ResponseWriter, r *http.Request, requireIfMatch bool) {
+	id, ok := queryID(w, r, "id")
+	if !ok {
+		return
+	}
+	version, err := ifMatch(r, requireIfMatch)
+	if err != nil {
+		writeIfMatchError(w, err)
+		return
+	}
+
+	// Поля необязательные: меняется только то, что передано.
+	var body struct {
+		Title    *string `json:"title"`
+		Done     *bool   `json:"done"`
+		Position *int    `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, `{"error":"Неверный формат данных"}`, http.StatusBadRequest)
+		log.Println("Неверный формат данных", err)
+		return
+	}
+	if body.Title != nil && !validItemTitle(*body.Title) {
+		http.Error(w, `{"error":"Не указан или слишком длинный текст пункта"}`, http.StatusBadRequest)
+		return
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при открытии транзакции", err)
+		return
+	}
+	defer tx.Rollback()
+
+	item, err := selectItem(tx, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error":"Пункт не найден"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	before, err := selectTask(tx, item.TaskID)
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	if !versionMatches(version, before) {
+		tx.Rollback()
+		writePreconditionFailed(w, before)
+		return
+	}
+
+	if body.Position != nil && *body.Position != item.Position {
+		n, err := countItems(tx, item.TaskID)
+		if err != nil {
+			http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+			log.Println("Ошибка базы данных", err)
+			return
+		}
+		to := *body.Position
+		if to < 1 || to > n {
+			http.Error(w, `{"error":"Неверная позиция пункта"}`, http.StatusBadRequest)
+			return
+		}
+		if to < item.Position {
+			_, err = tx.Exec(`UPDATE task_items SET position = position + 1
+				WHERE task_id = ? AND position >= ? AND position < ?`, item.TaskID, to, item.Position)
+		} else {
+			_, err = tx.Exec(`UPDATE task_items SET position = position - 1
+				WHERE task_id = ? AND position > ? AND position <= ?`, item.TaskID, item.Position, to)
+		}
+		if err != nil {
+			http.Error(w, `{"error":"Ошибка при обновлении пункта"}`, http.StatusInternalServerError)
+			log.Println("Ошибка при обновлении пункта", err)
+			return
+		}
+		item.Position = to
+	}
+	if body.Title != nil {
+		item.Title = strings.TrimSpace(*body.Title)
+	}
+	if body.Done != nil {
+		item.Done = *body.Done
+	}
+
+	start := time.Now()
+	_, err = tx.Exec(`UPDATE task_items SET position = ?, title = ?, done = ? WHERE id = ?`,
+		item.Position, item.Title, boolToInt(item.Done), id)
+	metrics.QueryDuration.Since(start, "update_task_item")
+	after := before
+	if err == nil {
+		after, err = itemsChanged(tx, r, before)
+	}
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		writeConflict(database, w, item.TaskID)
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при обновлении пункта"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при обновлении пункта", err)
+		return
+	}
+	taskChanged(r, webhook.TaskUpdated, &after)
+	writeItem(w, item, after)
+}
+
+func deleteItemHandler(database *db.DB, w http.ResponseWriter, r *http.Request, requireIfMatch bool) {
+	id, ok := queryID(w, r, "id")
+	if !ok {
+		return
+	}
+	version, err := ifMatch(r, requireIfMatch)
+	if err != nil {
+		writeIfMatchError(w, err)
+		return
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при открытии транзакции", err)
+		return
+	}
+	defer tx.Rollback()
+
+	item, err := selectItem(tx, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error":"Пункт не найден"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	before, err := selectTask(tx, item.TaskID)
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	if !versionMatches(version, before) {
+		tx.Rollback()
+		writePreconditionFailed(w, before)
+		return
+	}
+
+	start := time.Now()
+	_, err = tx.Exec(`DELETE FROM task_items WHERE id = ?`, id)
+	metrics.QueryDuration.Since(start, "delete_task_item")
+	if err == nil {
+		_, err = tx.Exec(`UPDATE task_items SET position = position - 1 WHERE task_id = ? AND position > ?`,
+			item.TaskID, item.Position)
+	}
+	after := before
+	if err == nil {
+		after, err = itemsChanged(tx, r, before)
+	}
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		writeConflict(database, w, item.TaskID)
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при удалении пункта"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при удалении пункта", err)
+		return
+	}
+	taskChanged(r, webhook.TaskUpdated, &after)
+
+	w.Header().Set("ETag", etag(after.Version))
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{}`))
+}
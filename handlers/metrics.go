@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"go_final_project/db"
+	"go_final_project/metrics"
+)
+
+func MetricsHandler(database *db.DB) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := `SELECT
+			COUNT(CASE WHEN date >= ? THEN 1 END),
+			COUNT(CASE WHEN date < ? THEN 1 END),
+			COUNT(CASE WHEN repeat != '' THEN 1 END)
+		FROM scheduler`
+		today := time.Now().Format(dateFormat)
+		var upcoming, overdue, recurring int
+		start := time.Now()
+		err := database.QueryRow(query, today, today).Scan(&upcoming, &overdue, &recurring)
+		metrics.QueryDuration.Since(start, "count_tasks")
+		if err != nil {
+			log.Println("Ошибка при подсчёте задач", err)
+		} else {
+			metrics.Tasks.Set(float64(upcoming), "upcoming")
+			metrics.Tasks.Set(float64(overdue), "overdue")
+			metrics.Tasks.Set(float64(recurring), "recurring")
+		}
+
+		metrics.Handler(w, r)
+	}
+}
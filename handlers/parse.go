@@ -0,0 +1,603 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var weekdayWords = map[string]int{
+	"понедельник": 1, "понедельника": 1, "понедельникам": 1, "пн": 1,
+	"вторник": 2, "вторника": 2, "вторникам": 2, "вт": 2,
+	"среда": 3, "среду": 3, "среды": 3, "средам": 3, "ср": 3,
+	"четверг": 4, "четверга": 4, "четвергам": 4, "чт": 4,
+	"пятница": 5, "пятницу": 5, "пятницы": 5, "пятницам": 5, "пт": 5,
+	"суббота": 6, "субботу": 6, "субботы": 6, "субботам": 6, "сб": 6,
+	"воскресенье": 7, "воскресенья": 7, "воскресеньям": 7, "вс": 7,
+	"monday": 1, "mondays": 1, "mon": 1,
+	"tuesday": 2, "tuesdays": 2, "tue": 2, "tues": 2,
+	"wednesday": 3, "wednesdays": 3, "wed": 3,
+	"thursday": 4, "thursdays": 4, "thu": 4, "thurs": 4,
+	"friday": 5, "fridays": 5, "fri": 5,
+	"saturday": 6, "saturdays": 6, "sat": 6,
+	"sunday": 7, "sundays": 7, "sun": 7,
+}
+
+var monthWords = map[string]time.Month{
+	"января": 1, "февраля": 2, "марта": 3, "апреля": 4, "мая": 5, "июня": 6,
+	"июля": 7, "августа": 8, "сентября": 9, "октября": 10, "ноября": 11, "декабря": 12,
+	"january": 1, "february": 2, "march": 3, "april": 4, "may": 5, "june": 6,
+	"july": 7, "august": 8, "september": 9, "october": 10, "november": 11, "december": 12,
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "jun": 6, "jul": 7, "aug": 8,
+	"sep": 9, "sept": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+const (
+	unitDay = iota + 1
+	unitWeek
+	unitMonth
+	unitYear
+)
+
+var unitWords = map[string]int{
+	"день": unitDay, "дня": unitDay, "дней": unitDay, "сутки": unitDay, "day": unitDay, "days": unitDay,
+	"неделя": unitWeek, "неделю": unitWeek, "недели": unitWeek, "недель": unitWeek, "week": unitWeek, "weeks": unitWeek,
+	"месяц": unitMonth, "месяца": unitMonth, "месяцев": unitMonth, "month": unitMonth, "months": unitMonth,
+	"год": unitYear, "года": unitYear, "лет": unitYear, "year": unitYear, "years": unitYear,
+}
+
+var numberWords = map[string]int{
+	"один": 1, "одну": 1, "одна": 1, "два": 2, "две": 2, "три": 3, "четыре": 4, "пять": 5,
+	"шесть": 6, "семь": 7, "восемь": 8, "девять": 9, "десять": 10,
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10, "other": 2,
+	// "каждый второй вторник", "every third day"
+	"второй": 2, "вторую": 2, "второе": 2, "третий": 3, "третью": 3, "третье": 3,
+	"second": 2, "third": 3,
+}
+
+// ordinalWords - номер дня недели в месяце: "второй вторник месяца".
+var ordinalWords = map[string]int{
+	"первый": 1, "первую": 1, "первое": 1, "второй": 2, "вторую": 2, "второе": 2,
+	"третий": 3, "третью": 3, "третье": 3, "четвертый": 4, "четвертую": 4, "четвертое": 4,
+	"пятый": 5, "пятую": 5, "пятое": 5, "последний": -1, "последнюю": -1, "последнее": -1,
+	"предпоследний": -2, "предпоследнюю": -2, "предпоследнее": -2,
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "last": -1,
+}
+
+// Слова, которые не меняют смысла: "в понедельник", "on monday", "каждый месяц 15 числа".
+var fillerWords = map[string]bool{
+	"в": true, "во": true, "и": true, "с": true, "со": true, "по": true, "на": true,
+	"числа": true, "число": true, "каждого": true, "месяца": true,
+	"on": true, "and": true, "the": true, "of": true, "at": true, "from": true, "starting": true, "next": true,
+	"следующий": true, "следующую": true, "следующее": true,
+}
+
+var businessWords = map[string]bool{
+	"рабочий": true, "рабочих": true, "рабочие": true, "рабочего": true,
+	"business": true, "working": true, "work": true,
+}
+
+var everyWords = map[string]bool{
+	"каждый": true, "каждую": true, "каждое": true, "каждые": true, "every": true, "each": true,
+}
+
+// whenParser разбирает текст по словам слева направо.
+type whenParser struct {
+	today  time.Time
+	words  []string
+	pos    int
+	date   time.Time
+	repeat string
+	// monthInterval - N из "каждые N месяцев", пока не известен день месяца.
+	monthInterval int
+}
+
+func (p *whenParser) peek(offset int) string {
+	if p.pos+offset < len(p.words) {
+		return p.words[p.pos+offset]
+	}
+	return ""
+}
+
+// number читает число цифрами, словом или порядковое ("15th").
+func (p *whenParser) number() (int, bool) {
+	word := p.peek(0)
+	if n, ok := numberWords[word]; ok {
+		p.pos++
+		return n, true
+	}
+	for _, suffix := range []string{"st", "nd", "rd", "th", "-го", "-е"} {
+		word = strings.TrimSuffix(word, suffix)
+	}
+	n, err := strconv.Atoi(word)
+	if err != nil || n <= 0 || len(word) > 4 {
+		return 0, false
+	}
+	p.pos++
+	return n, true
+}
+
+func (p *whenParser) setDate(d time.Time) error {
+	if !p.date.IsZero() {
+		return fmt.Errorf("дата указана дважды")
+	}
+	p.date = d
+	return nil
+}
+
+func (p *whenParser) setRepeat(repeat string) error {
+	if p.repeat != "" {
+		return fmt.Errorf("правило повторения указано дважды")
+	}
+	p.repeat = repeat
+	return nil
+}
+
+// weekdays читает список дней недели: "понедельник и среду", "mon, wed".
+func (p *whenParser) weekdays() string {
+	var days []string
+	seen := map[int]bool{}
+	for {
+		start := p.pos
+		for fillerWords[p.peek(0)] {
+			p.pos++
+		}
+		day, ok := weekdayWords[p.peek(0)]
+		if !ok {
+			p.pos = start
+			break
+		}
+		p.pos++
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, strconv.Itoa(day))
+		}
+	}
+	return strings.Join(days, ",")
+}
+
+// lastDayOfMonth читает "последний день месяца" или "last day of (the) month".
+func (p *whenParser) lastDayOfMonth() bool {
+	if p.peek(0) != "последний" && p.peek(0) != "last" {
+		return false
+	}
+	if unitWords[p.peek(1)] != unitDay {
+		return false
+	}
+	p.pos += 2
+	for fillerWords[p.peek(0)] && unitWords[p.peek(0)] != unitMonth {
+		p.pos++
+	}
+	if unitWords[p.peek(0)] == unitMonth {
+		p.pos++
+	}
+	return true
+}
+
+// nthWeekdays читает "второй вторник и последнюю пятницу месяца",
+// "last friday of the month". Без слова "месяц" порядковое число не
+// отличить от "каждый второй вторник", поэтому оно обязательно.
+func (p *whenParser) nthWeekdays() string {
+	start := p.pos
+	var pairs []string
+	for {
+		pos := p.pos
+		if len(pairs) > 0 && (p.peek(0) == "и" || p.peek(0) == "and") {
+			p.pos++
+		}
+		n, isOrdinal := ordinalWords[p.peek(0)]
+		day, isDay := weekdayWords[p.peek(1)]
+		if !isOrdinal || !isDay {
+			p.pos = pos
+			break
+		}
+		p.pos += 2
+		pairs = append(pairs, fmt.Sprintf("%d:%d", n, day))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	for (fillerWords[p.peek(0)] || everyWords[p.peek(0)]) && unitWords[p.peek(0)] != unitMonth {
+		p.pos++
+	}
+	if unitWords[p.peek(0)] != unitMonth {
+		p.pos = start
+		return ""
+	}
+	p.pos++
+	return "mw " + strings.Join(pairs, ",")
+}
+
+// every читает правило после "каждый" / "every".
+func (p *whenParser) every() error {
+	if rule := p.nthWeekdays(); rule != "" {
+		return p.setRepeat(rule)
+	}
+	if p.lastDayOfMonth() {
+		return p.setRepeat("m -1")
+	}
+	if days := p.weekdays(); days != "" {
+		return p.setRepeat("w " + days)
+	}
+	if p.peek(0) == "будний" || p.peek(0) == "weekday" {
+		p.pos++
+		if unitWords[p.peek(0)] == unitDay {
+			p.pos++
+		}
+		return p.setRepeat("w 1,2,3,4,5")
+	}
+
+	if day, ok := p.dayOfMonth(); ok {
+		return p.monthDay(day)
+	}
+	n, ok := p.number()
+	if !ok {
+		n = 1
+	}
+	// "every other friday", "каждые 2 недели по средам"
+	if days := p.weekdays(); days != "" {
+		return p.setRepeat(weekRule(days, n))
+	}
+	// "каждый рабочий день", "every 5 business days"
+	if businessWords[p.peek(0)] && unitWords[p.peek(1)] == unitDay {
+		p.pos += 2
+		if n > 400 {
+			return fmt.Errorf("интервал больше 400 рабочих дней не поддерживается")
+		}
+		return p.setRepeat("bd " + strconv.Itoa(n))
+	}
+	unit, isUnit := unitWords[p.peek(0)]
+	if !isUnit {
+		return fmt.Errorf("не удалось разобрать правило повторения")
+	}
+	p.pos++
+	return p.interval(n, unit)
+}
+
+// dayOfMonth читает день месяца: "15 числа", "15-го", "15th".
+func (p *whenParser) dayOfMonth() (int, bool) {
+	word := p.peek(0)
+	start := p.pos
+	n, ok := p.number()
+	if !ok {
+		return 0, false
+	}
+	if p.peek(0) == "число" || p.peek(0) == "числа" {
+		p.pos++
+		return n, true
+	}
+	for _, suffix := range []string{"st", "nd", "rd", "th", "-го"} {
+		if strings.HasSuffix(word, suffix) && word[0] >= '0' && word[0] <= '9' {
+			return n, true
+		}
+	}
+	p.pos = start
+	return 0, false
+}
+
+// monthDay задаёт повтор в день месяца; уточняет "каждый месяц".
+func (p *whenParser) monthDay(day int) error {
+	if day > 31 {
+		return fmt.Errorf("неверный день месяца %d", day)
+	}
+	if p.repeat == "m" {
+		p.repeat = ""
+	}
+	return p.setRepeat(monthRule(day, p.monthInterval))
+}
+
+// interval задаёт повтор через n дней, недель, месяцев или лет.
+func (p *whenParser) interval(n, unit int) error {
+	switch unit {
+	case unitDay:
+		if n > 400 {
+			return fmt.Errorf("интервал больше 400 дней не поддерживается")
+		}
+		return p.setRepeat("d " + strconv.Itoa(n))
+	case unitWeek:
+		// "каждые 2 недели по средам"
+		if days := p.weekdays(); days != "" {
+			return p.setRepeat(weekRule(days, n))
+		}
+		if n*7 > 400 {
+			return fmt.Errorf("интервал больше 400 дней не поддерживается")
+		}
+		return p.setRepeat("d " + strconv.Itoa(n*7))
+	case unitMonth:
+		if n > 24 {
+			return fmt.Errorf("интервал больше 24 месяцев не поддерживается")
+		}
+		// День месяца берётся из даты задачи, когда она известна.
+		p.monthInterval = n
+		return p.setRepeat("m")
+	default:
+		if n > 100 {
+			return fmt.Errorf("интервал больше 100 лет не поддерживается")
+		}
+		if n == 1 {
+			return p.setRepeat("y")
+		}
+		return p.setRepeat("y " + strconv.Itoa(n))
+	}
+}
+
+// weekRule строит правило w с интервалом в неделях.
+func weekRule(days string, n int) string {
+	if n == 1 {
+		return "w " + days
+	}
+	return "w " + days + " /" + strconv.Itoa(n)
+}
+
+// monthRule строит правило m для дня месяца с интервалом в месяцах.
+func monthRule(day, n int) string {
+	if n <= 1 {
+		return "m " + strconv.Itoa(day)
+	}
+	return "m " + strconv.Itoa(day) + " /" + strconv.Itoa(n)
+}
+
+// nearestWeekday возвращает ближайший после сегодняшнего день недели.
+func (p *whenParser) nearestWeekday(day int) time.Time {
+	d := p.today.AddDate(0, 0, 1)
+	for (int(d.Weekday())+6)%7+1 != day {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// calendarDate читает "1 марта [2025]", "march 1 [2025]", "01.03.2024", "20240301".
+func (p *whenParser) calendarDate() (time.Time, bool, error) {
+	word := p.peek(0)
+	if len(word) == 8 && strings.Trim(word, "0123456789") == "" {
+		d, err := time.ParseInLocation(dateFormat, word, p.today.Location())
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("неверная дата %s", word)
+		}
+		p.pos++
+		return d, true, nil
+	}
+	if strings.Count(word, ".") > 0 && strings.Trim(word, "0123456789.") == "" {
+		for _, layout := range []string{"2.1.2006", "2.1"} {
+			d, err := time.ParseInLocation(layout, word, p.today.Location())
+			if err == nil {
+				p.pos++
+				if layout == "2.1" {
+					return p.withYear(d.Day(), d.Month(), 0)
+				}
+				return d, true, nil
+			}
+		}
+		return time.Time{}, false, fmt.Errorf("неверная дата %s", word)
+	}
+
+	start := p.pos
+	day, ok := p.number()
+	month, isMonth := monthWords[p.peek(0)]
+	if ok && isMonth {
+		p.pos++
+	} else {
+		p.pos = start
+		month, isMonth = monthWords[p.peek(0)]
+		if !isMonth {
+			return time.Time{}, false, nil
+		}
+		p.pos++
+		if day, ok = p.number(); !ok {
+			p.pos = start
+			return time.Time{}, false, nil
+		}
+	}
+	year := 0
+	if n, err := strconv.Atoi(p.peek(0)); err == nil && len(p.peek(0)) == 4 {
+		year = n
+		p.pos++
+	}
+	return p.withYear(day, month, year)
+}
+
+// withYear собирает дату; без года берётся ближайшая такая дата не раньше сегодня.
+func (p *whenParser) withYear(day int, month time.Month, year int) (time.Time, bool, error) {
+	y := year
+	if y == 0 {
+		y = p.today.Year()
+	}
+	d := time.Date(y, month, day, 0, 0, 0, 0, p.today.Location())
+	if d.Day() != day {
+		return time.Time{}, false, fmt.Errorf("такой даты нет: %d.%02d", day, month)
+	}
+	if year == 0 && d.Before(p.today) {
+		d = d.AddDate(1, 0, 0)
+	}
+	return d, true, nil
+}
+
+func (p *whenParser) parse() error {
+	for p.pos < len(p.words) {
+		word := p.peek(0)
+		var err error
+		switch {
+		case word == "сегодня" || word == "today":
+			p.pos++
+			err = p.setDate(p.today)
+		case word == "завтра" || word == "tomorrow":
+			p.pos++
+			err = p.setDate(p.today.AddDate(0, 0, 1))
+		case word == "послезавтра":
+			p.pos++
+			err = p.setDate(p.today.AddDate(0, 0, 2))
+		case word == "day" && p.peek(1) == "after" && p.peek(2) == "tomorrow":
+			p.pos += 3
+			err = p.setDate(p.today.AddDate(0, 0, 2))
+		case word == "через" || word == "in":
+			p.pos++
+			n, ok := p.number()
+			if !ok {
+				n = 1
+			}
+			unit, isUnit := unitWords[p.peek(0)]
+			if !isUnit {
+				return fmt.Errorf("после «%s» ожидается срок, например «%s 3 дня»", word, word)
+			}
+			p.pos++
+			switch unit {
+			case unitDay:
+				err = p.setDate(p.today.AddDate(0, 0, n))
+			case unitWeek:
+				err = p.setDate(p.today.AddDate(0, 0, 7*n))
+			case unitMonth:
+				err = p.setDate(p.today.AddDate(0, n, 0))
+			default:
+				err = p.setDate(p.today.AddDate(n, 0, 0))
+			}
+		case everyWords[word]:
+			p.pos++
+			err = p.every()
+		case word == "ежедневно" || word == "daily":
+			p.pos++
+			err = p.setRepeat("d 1")
+		case word == "еженедельно" || word == "weekly":
+			p.pos++
+			err = p.setRepeat("d 7")
+		case word == "ежемесячно" || word == "monthly":
+			p.pos++
+			err = p.setRepeat("m")
+		case word == "ежегодно" || word == "yearly" || word == "annually":
+			p.pos++
+			err = p.setRepeat("y")
+		case word == "по" && (p.peek(1) == "будням" || p.peek(1) == "выходным"):
+			repeat := "w 6,7"
+			if p.peek(1) == "будням" {
+				repeat = "w 1,2,3,4,5"
+			}
+			p.pos += 2
+			err = p.setRepeat(repeat)
+		case word == "по" && strings.HasSuffix(p.peek(1), "ам") && weekdayWords[p.peek(1)] != 0:
+			// "по понедельникам и средам"
+			p.pos++
+			err = p.setRepeat("w " + p.weekdays())
+		case p.lastDayOfMonth():
+			err = p.setRepeat("m -1")
+		case ordinalWords[word] != 0 && weekdayWords[p.peek(1)] != 0:
+			rule := p.nthWeekdays()
+			if rule == "" {
+				return fmt.Errorf("уточните «%s %s месяца»", word, p.peek(1))
+			}
+			err = p.setRepeat(rule)
+		case weekdayWords[word] != 0:
+			p.pos++
+			err = p.setDate(p.nearestWeekday(weekdayWords[word]))
+		case fillerWords[word]:
+			p.pos++
+		default:
+			d, ok, dateErr := p.calendarDate()
+			if dateErr != nil {
+				return dateErr
+			}
+			if ok {
+				err = p.setDate(d)
+			} else if day, isDay := p.dayOfMonth(); isDay {
+				err = p.monthDay(day)
+			} else {
+				return fmt.Errorf("не удалось разобрать «%s»", word)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseWhen переводит описание срока на русском или английском ("завтра",
+// "через 3 дня", "каждый понедельник", "every 2 weeks", "last day of month")
+// в дату и правило повторения в формате NextDate. Если указано только
+// правило, дата - первый подходящий день начиная с сегодняшнего.
+func ParseWhen(now time.Time, text string) (date string, repeat string, err error) {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ',' || r == ';' || r == '!' || r == '?'
+	})
+	for i, w := range words {
+		words[i] = strings.TrimSuffix(w, ".")
+	}
+	if len(words) == 0 {
+		return "", "", fmt.Errorf("пустое описание срока")
+	}
+
+	p := &whenParser{
+		today: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()),
+		words: words,
+	}
+	if err := p.parse(); err != nil {
+		return "", "", err
+	}
+
+	if p.repeat == "m" {
+		// "каждый месяц" - в тот же день месяца, что и первая дата.
+		day := p.today.Day()
+		if !p.date.IsZero() {
+			day = p.date.Day()
+		}
+		p.repeat = monthRule(day, p.monthInterval)
+	}
+	if p.date.IsZero() {
+		if p.repeat == "" {
+			return "", "", fmt.Errorf("не удалось разобрать срок")
+		}
+		p.date = p.today
+		if strings.HasPrefix(p.repeat, "bd ") {
+			// Первый рабочий день начиная с сегодняшнего.
+			yesterday := p.today.AddDate(0, 0, -1)
+			next, err := NextDate(yesterday, yesterday.Format(dateFormat), "bd 1")
+			if err != nil {
+				return "", "", err
+			}
+			return next, p.repeat, nil
+		}
+		if p.repeat[0] == 'w' || p.repeat[0] == 'm' {
+			// Первый подходящий день не раньше сегодняшнего. Интервал "/N"
+			// отсчитывается от даты задачи, поэтому при поиске её не учитываем.
+			first, _, _ := strings.Cut(p.repeat, " /")
+			yesterday := p.today.AddDate(0, 0, -1)
+			next, err := NextDate(yesterday, yesterday.Format(dateFormat), first)
+			if err != nil {
+				return "", "", err
+			}
+			return next, p.repeat, nil
+		}
+	}
+	return p.date.Format(dateFormat), p.repeat, nil
+}
+
+// ParseHandler отвечает на GET /api/parse?text=...[&now=ГГГГММДД].
+func ParseHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	if s := r.URL.Query().Get("now"); s != "" {
+		var err error
+		now, err = time.Parse(dateFormat, s)
+		if err != nil {
+			http.Error(w, `{"error":"Неверный формат даты now"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	date, repeat, err := ParseWhen(now, r.URL.Query().Get("text"))
+	if err != nil {
+		body, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(body), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"date": date, "repeat": repeat})
+}
@@ -0,0 +1,487 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_final_project/calendar"
+)
+
+// repeatRule - разобранное правило повторения задачи.
+type repeatRule struct {
+	kind string
+	// n - шаг в днях для d, в рабочих днях для bd и в годах для y.
+	n int
+	// days - дни недели для w, дни месяца для m (отрицательные - с конца месяца).
+	days map[int]bool
+	// nth - для mw: пары "какой по счёту в месяце" и день недели.
+	nth    []nthWeekday
+	months map[int]bool
+	// interval - для w и m: каждая interval-я неделя или месяц, считая
+	// от недели (месяца) даты задачи.
+	interval int
+	// shift - перенос вычисленной даты на рабочий день: 1 - на следующий,
+	// -1 - на предыдущий.
+	shift int
+}
+
+// nthWeekday - n-й день недели месяца; n < 0 считается с конца месяца.
+type nthWeekday struct {
+	n, weekday int
+}
+
+// RepeatParam и RepeatSyntax описывают грамматику правил для GET /api/repeat.
+type RepeatParam struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Min         int    `json:"min"`
+	Max         int    `json:"max"`
+	List        bool   `json:"list,omitempty"`
+	Optional    bool   `json:"optional,omitempty"`
+	// Prefix - символ перед значением, например "/" у интервала.
+	Prefix string `json:"prefix,omitempty"`
+}
+
+type RepeatSyntax struct {
+	Rule        string        `json:"rule"`
+	Syntax      string        `json:"syntax"`
+	Description string        `json:"description"`
+	Params      []RepeatParam `json:"params"`
+	Examples    []string      `json:"examples"`
+}
+
+var repeatGrammar = []RepeatSyntax{
+	{
+		Rule:        "d",
+		Syntax:      "d N",
+		Description: "каждые N дней от даты задачи",
+		Params: []RepeatParam{
+			{Name: "N", Description: "шаг в днях", Min: 1, Max: 400},
+		},
+		Examples: []string{"d 1", "d 7"},
+	},
+	{
+		Rule:        "bd",
+		Syntax:      "bd N",
+		Description: "каждые N рабочих дней от даты задачи; рабочие дни - понедельник-пятница с учётом производственного календаря",
+		Params: []RepeatParam{
+			{Name: "N", Description: "шаг в рабочих днях", Min: 1, Max: 400},
+		},
+		Examples: []string{"bd 1", "bd 5"},
+	},
+	{
+		Rule:        "w",
+		Syntax:      "w ДНИ [/N]",
+		Description: "в указанные дни недели; с /N - только каждую N-ю неделю, считая от недели даты задачи",
+		Params: []RepeatParam{
+			{Name: "ДНИ", Description: "дни недели, 1 - понедельник, 7 - воскресенье", Min: 1, Max: 7, List: true},
+			{Name: "N", Description: "интервал в неделях", Min: 1, Max: 52, Optional: true, Prefix: "/"},
+		},
+		Examples: []string{"w 7", "w 1,3,5", "w 5 /2"},
+	},
+	{
+		Rule:        "m",
+		Syntax:      "m ДНИ [МЕСЯЦЫ | /N]",
+		Description: "в указанные дни месяца; -1 - последний день, -2 - предпоследний; с МЕСЯЦАМИ - только в эти месяцы, с /N - каждый N-й месяц, считая от месяца даты задачи",
+		Params: []RepeatParam{
+			{Name: "ДНИ", Description: "дни месяца", Min: -2, Max: 31, List: true},
+			{Name: "МЕСЯЦЫ", Description: "номера месяцев", Min: 1, Max: 12, List: true, Optional: true},
+			{Name: "N", Description: "интервал в месяцах", Min: 1, Max: 24, Optional: true, Prefix: "/"},
+		},
+		Examples: []string{"m 4", "m -1", "m 1,15 3,6,9,12", "m 15 /3"},
+	},
+	{
+		Rule:        "mw",
+		Syntax:      "mw N:ДЕНЬ[,N:ДЕНЬ] [МЕСЯЦЫ | /N]",
+		Description: "в N-й день недели месяца, например 2:2 - второй вторник; отрицательное N считается с конца месяца, -1:5 - последняя пятница; МЕСЯЦЫ и /N - как у m",
+		Params: []RepeatParam{
+			{Name: "N", Description: "номер дня недели в месяце, с конца - отрицательный", Min: -5, Max: 5, List: true},
+			{Name: "ДЕНЬ", Description: "день недели, 1 - понедельник, 7 - воскресенье", Min: 1, Max: 7, List: true},
+			{Name: "МЕСЯЦЫ", Description: "номера месяцев", Min: 1, Max: 12, List: true, Optional: true},
+			{Name: "N", Description: "интервал в месяцах", Min: 1, Max: 24, Optional: true, Prefix: "/"},
+		},
+		Examples: []string{"mw 2:2", "mw -1:5", "mw 2:2,-1:5", "mw 1:1 1,4,7,10", "mw -1:7 /2"},
+	},
+	{
+		Rule:        "y",
+		Syntax:      "y [N]",
+		Description: "раз в N лет в тот же день, что и дата задачи",
+		Params: []RepeatParam{
+			{Name: "N", Description: "шаг в годах", Min: 1, Max: 100, Optional: true},
+		},
+		Examples: []string{"y", "y 2"},
+	},
+}
+
+// repeatModifiers дописываются в конец любого правила.
+var repeatModifiers = []RepeatSyntax{
+	{
+		Rule:        ">",
+		Syntax:      "ПРАВИЛО >",
+		Description: "если дата выпала на выходной или праздник, перенести её на следующий рабочий день",
+		Params:      []RepeatParam{},
+		Examples:    []string{"m 25 >", "d 14 >"},
+	},
+	{
+		Rule:        "<",
+		Syntax:      "ПРАВИЛО <",
+		Description: "если дата выпала на выходной или праздник, перенести её на предыдущий рабочий день",
+		Params:      []RepeatParam{},
+		Examples:    []string{"m -1 <", "mw -1:5 <"},
+	},
+}
+
+// parseNumbers разбирает список чисел через запятую из диапазона [min, max].
+func parseNumbers(list string, min, max int) (map[int]bool, error) {
+	numbers := make(map[int]bool)
+	for _, s := range strings.Split(list, ",") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return nil, fmt.Errorf("неверное значение %s", s)
+		}
+		numbers[n] = true
+	}
+	return numbers, nil
+}
+
+// parseInterval разбирает "/N" из диапазона [1, max].
+func parseInterval(s string, max int) (int, bool) {
+	if !strings.HasPrefix(s, "/") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[1:])
+	if err != nil || n < 1 || n > max {
+		return 0, false
+	}
+	return n, true
+}
+
+// ValidRepeat проверяет правило повторения, не вычисляя дат: так чат-бот
+// отделяет правило от названия задачи.
+func ValidRepeat(repeat string) error {
+	_, err := parseRepeat(repeat)
+	return err
+}
+
+func parseRepeat(repeat string) (repeatRule, error) {
+	parts := strings.Fields(repeat)
+	if len(parts) == 0 {
+		return repeatRule{}, fmt.Errorf("пустое правило повторения")
+	}
+	rule := repeatRule{kind: parts[0], interval: 1}
+	if last := parts[len(parts)-1]; len(parts) > 1 && (last == ">" || last == "<") {
+		rule.shift = 1
+		if last == "<" {
+			rule.shift = -1
+		}
+		parts = parts[:len(parts)-1]
+	}
+
+	switch parts[0] {
+	case "d":
+		if len(parts) != 2 {
+			return rule, fmt.Errorf("неверный формат repeat")
+		}
+		days, err := strconv.Atoi(parts[1])
+		if err != nil || days <= 0 || days > 400 {
+			return rule, fmt.Errorf("неверное количество дней в repeat")
+		}
+		rule.n = days
+
+	case "bd":
+		if len(parts) != 2 {
+			return rule, fmt.Errorf("неверный формат repeat")
+		}
+		days, err := strconv.Atoi(parts[1])
+		if err != nil || days <= 0 || days > 400 {
+			return rule, fmt.Errorf("неверное количество рабочих дней в repeat")
+		}
+		rule.n = days
+
+	case "y":
+		rule.n = 1
+		if len(parts) > 2 {
+			return rule, fmt.Errorf("неверный формат repeat")
+		}
+		if len(parts) == 2 {
+			years, err := strconv.Atoi(parts[1])
+			if err != nil || years <= 0 || years > 100 {
+				return rule, fmt.Errorf("неверное количество лет в repeat")
+			}
+			rule.n = years
+		}
+
+	case "w":
+		if len(parts) != 2 && len(parts) != 3 {
+			return rule, fmt.Errorf("неверный формат repeat")
+		}
+		var err error
+		rule.days, err = parseNumbers(parts[1], 1, 7)
+		if err != nil {
+			return rule, fmt.Errorf("неверный день недели в repeat")
+		}
+		if len(parts) == 3 {
+			var ok bool
+			if rule.interval, ok = parseInterval(parts[2], 52); !ok {
+				return rule, fmt.Errorf("неверный интервал в неделях в repeat")
+			}
+		}
+
+	case "m":
+		if len(parts) != 2 && len(parts) != 3 {
+			return rule, fmt.Errorf("неверный формат repeat")
+		}
+		var err error
+		rule.days, err = parseNumbers(parts[1], -2, 31)
+		if err != nil || rule.days[0] {
+			return rule, fmt.Errorf("неверный день месяца в repeat")
+		}
+		if len(parts) == 3 {
+			if err := rule.parseMonths(parts[2]); err != nil {
+				return rule, err
+			}
+		}
+
+	case "mw":
+		if len(parts) != 2 && len(parts) != 3 {
+			return rule, fmt.Errorf("неверный формат repeat")
+		}
+		for _, pair := range strings.Split(parts[1], ",") {
+			ns, ds, _ := strings.Cut(pair, ":")
+			n, err := strconv.Atoi(ns)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return rule, fmt.Errorf("неверный номер дня недели в месяце в repeat")
+			}
+			weekday, err := strconv.Atoi(ds)
+			if err != nil || weekday < 1 || weekday > 7 {
+				return rule, fmt.Errorf("неверный день недели в repeat")
+			}
+			rule.nth = append(rule.nth, nthWeekday{n: n, weekday: weekday})
+		}
+		if len(parts) == 3 {
+			if err := rule.parseMonths(parts[2]); err != nil {
+				return rule, err
+			}
+		}
+
+	default:
+		return rule, fmt.Errorf("неизвестный формат repeat")
+	}
+	return rule, nil
+}
+
+// parseMonths разбирает фильтр месяцев правил m и mw: список или "/N".
+func (r *repeatRule) parseMonths(s string) error {
+	if strings.HasPrefix(s, "/") {
+		var ok bool
+		if r.interval, ok = parseInterval(s, 24); !ok {
+			return fmt.Errorf("неверный интервал в месяцах в repeat")
+		}
+		return nil
+	}
+	var err error
+	if r.months, err = parseNumbers(s, 1, 12); err != nil {
+		return fmt.Errorf("неверный месяц в repeat")
+	}
+	return nil
+}
+
+// weekIndex и monthIndex нумеруют недели (с понедельника) и месяцы подряд,
+// чтобы считать интервалы от даты задачи.
+func weekIndex(d time.Time) int {
+	days := int(time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+	// 1 января 1970 года - четверг.
+	days += 3
+	if days < 0 {
+		days -= 6
+	}
+	return days / 7
+}
+
+// isoWeekday возвращает день недели от 1 (понедельник) до 7 (воскресенье).
+func isoWeekday(d time.Time) int {
+	if d.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(d.Weekday())
+}
+
+func monthIndex(d time.Time) int {
+	return d.Year()*12 + int(d.Month()) - 1
+}
+
+func (r repeatRule) match(taskDate, d time.Time) bool {
+	switch r.kind {
+	case "w":
+		return r.days[isoWeekday(d)] && (weekIndex(d)-weekIndex(taskDate))%r.interval == 0
+	}
+
+	if r.months != nil && !r.months[int(d.Month())] {
+		return false
+	}
+	if (monthIndex(d)-monthIndex(taskDate))%r.interval != 0 {
+		return false
+	}
+	last := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, d.Location()).Day()
+	if r.kind == "m" {
+		return r.days[d.Day()] || r.days[d.Day()-last-1]
+	}
+	for _, nth := range r.nth {
+		if nth.weekday != isoWeekday(d) {
+			continue
+		}
+		if nth.n > 0 && (d.Day()-1)/7+1 == nth.n || nth.n < 0 && (last-d.Day())/7+1 == -nth.n {
+			return true
+		}
+	}
+	return false
+}
+
+// workingDay сдвигает дату на рабочий день в направлении dir (1 или -1).
+func workingDay(d time.Time, dir int) (time.Time, error) {
+	for i := 0; i < 366; i++ {
+		if calendar.Default.Working(d) {
+			return d, nil
+		}
+		d = d.AddDate(0, 0, dir)
+	}
+	return d, fmt.Errorf("в календаре нет рабочих дней")
+}
+
+// next возвращает следующую дату после taskDate и после now.
+func (r repeatRule) next(now, taskDate time.Time) (string, error) {
+	now = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, taskDate.Location())
+	if r.shift == 0 {
+		next, err := r.nextUnshifted(now, taskDate)
+		if err != nil {
+			return "", err
+		}
+		return next.Format(dateFormat), nil
+	}
+
+	// При переносе назад дата может оказаться не позже now или даты задачи
+	// (задача уже перенесена на этот день) - тогда берём следующую.
+	after := now
+	for i := 0; i < 1000; i++ {
+		next, err := r.nextUnshifted(after, taskDate)
+		if err != nil {
+			return "", err
+		}
+		shifted, err := workingDay(next, r.shift)
+		if err != nil {
+			return "", err
+		}
+		if shifted.After(now) && shifted.After(taskDate) {
+			return shifted.Format(dateFormat), nil
+		}
+		after = next
+	}
+	return "", fmt.Errorf("не удалось перенести дату на рабочий день")
+}
+
+func (r repeatRule) nextUnshifted(now, taskDate time.Time) (time.Time, error) {
+	switch r.kind {
+	case "d":
+		taskDate = taskDate.AddDate(0, 0, r.n)
+		for !taskDate.After(now) {
+			taskDate = taskDate.AddDate(0, 0, r.n)
+		}
+		return taskDate, nil
+	case "bd":
+		for {
+			for i := 0; i < r.n; i++ {
+				var err error
+				if taskDate, err = workingDay(taskDate.AddDate(0, 0, 1), 1); err != nil {
+					return taskDate, err
+				}
+			}
+			if taskDate.After(now) {
+				return taskDate, nil
+			}
+		}
+	case "y":
+		taskDate = taskDate.AddDate(r.n, 0, 0)
+		for !taskDate.After(now) {
+			taskDate = taskDate.AddDate(r.n, 0, 0)
+		}
+		return taskDate, nil
+	}
+
+	// Правила вроде "m 31 2" не выполняются никогда, поэтому поиск ограничен;
+	// при интервале в N месяцев все месяцы года перебираются за N лет.
+	day := taskDate
+	if now.After(day) {
+		day = now
+	}
+	limit := day.AddDate(8, 0, 0)
+	if r.kind != "w" && r.interval > 8 {
+		limit = day.AddDate(r.interval+1, 0, 0)
+	}
+	for day = day.AddDate(0, 0, 1); day.Before(limit); day = day.AddDate(0, 0, 1) {
+		if r.match(taskDate, day) {
+			return day, nil
+		}
+	}
+	return day, fmt.Errorf("правило repeat не выполняется ни в один день")
+}
+
+// RepeatHandler без параметра repeat возвращает грамматику правил, а с ним
+// проверяет правило и показывает ближайшие даты:
+// GET /api/repeat?repeat=w+5+/2[&date=ГГГГММДД][&now=ГГГГММДД][&count=5].
+func RepeatHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	if !q.Has("repeat") {
+		json.NewEncoder(w).Encode(map[string]interface{}{"rules": repeatGrammar, "modifiers": repeatModifiers})
+		return
+	}
+
+	now := time.Now()
+	if s := q.Get("now"); s != "" {
+		var err error
+		if now, err = time.Parse(dateFormat, s); err != nil {
+			http.Error(w, `{"error":"Неверный формат даты now"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	date := now.Format(dateFormat)
+	if s := q.Get("date"); s != "" {
+		if _, err := time.Parse(dateFormat, s); err != nil {
+			http.Error(w, `{"error":"Неверный формат даты"}`, http.StatusBadRequest)
+			return
+		}
+		date = s
+	}
+	count := 5
+	if s := q.Get("count"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 50 {
+			http.Error(w, `{"error":"count должен быть от 1 до 50"}`, http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+
+	repeat := q.Get("repeat")
+	next := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		d, err := nextDate(now, date, repeat)
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"valid": false, "error": err.Error()})
+			return
+		}
+		next = append(next, d)
+		now, _ = time.Parse(dateFormat, d)
+		date = d
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"valid": true, "repeat": repeat, "next": next})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const tokenCookie = "token"
+
+// Веб-интерфейс после входа сам ставит cookie с токеном на 8 часов.
+const tokenTTL = 8 * time.Hour
+
+// hardenedTokens помнит, когда cookie с токеном была переустановлена с флагами
+// безопасности. Каждый токен переустанавливается один раз и с тем же сроком,
+// поэтому сессия не продлевается запросами.
+var hardenedTokens = struct {
+	sync.Mutex
+	expires map[string]time.Time
+}{expires: map[string]time.Time{}}
+
+// hardenToken сообщает, когда истекает cookie с токеном, если её нужно
+// переустановить, и false, если это уже сделано.
+func hardenToken(token string, now time.Time) (time.Time, bool) {
+	hardenedTokens.Lock()
+	defer hardenedTokens.Unlock()
+	if _, ok := hardenedTokens.expires[token]; ok {
+		return time.Time{}, false
+	}
+	for t, expires := range hardenedTokens.expires {
+		if now.After(expires) {
+			delete(hardenedTokens.expires, t)
+		}
+	}
+	expires := now.Add(tokenTTL)
+	hardenedTokens.expires[token] = expires
+	return expires, true
+}
+
+func SecureHeaders(next http.Handler, hstsMaxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			w.Header().Set("Strict-Transport-Security",
+				fmt.Sprintf("max-age=%d; includeSubDomains", int(hstsMaxAge.Seconds())))
+
+			// Скрипт не может поставить HttpOnly, поэтому cookie, выставленную
+			// веб-интерфейсом, сервер переустанавливает со всеми флагами.
+			if c, err := r.Cookie(tokenCookie); err == nil && c.Value != "" {
+				if expires, ok := hardenToken(c.Value, time.Now()); ok {
+					http.SetCookie(w, &http.Cookie{
+						Name:     tokenCookie,
+						Value:    c.Value,
+						Path:     "/",
+						Expires:  expires,
+						HttpOnly: true,
+						Secure:   true,
+						SameSite: http.SameSiteStrictMode,
+					})
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func RedirectToHTTPS(httpsPort string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go_final_project/db"
+	"go_final_project/metrics"
+)
+
+const maxTagLength = 64
+
+// normalizeTags приводит теги к нижнему регистру, убирает повторы
+// и сортирует их.
+func normalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLength || strings.ContainsAny(tag, ", \t\n") {
+			return nil, errors.New("неверное имя тега")
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func taskTags(q db.Queryer, taskID interface{}) ([]string, error) {
+	query := `SELECT t.name FROM tags t JOIN task_tags tt ON tt.tag_id = t.id WHERE tt.task_id = ? ORDER BY t.name`
+	start := time.Now()
+	rows, err := q.Query(query, taskID)
+	metrics.QueryDuration.Since(start, "select_task_tags")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// tagsByTask возвращает теги сразу для нескольких задач.
+func tagsByTask(q db.Queryer, ids []string) (map[string][]string, error) {
+	result := map[string][]string{}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := `SELECT tt.task_id, t.name FROM task_tags tt JOIN tags t ON t.id = tt.tag_id
+		WHERE tt.task_id IN (?` + strings.Repeat(", ?", len(ids)-1) + `) ORDER BY t.name`
+	start := time.Now()
+	rows, err := q.Query(query, args...)
+	metrics.QueryDuration.Since(start, "select_task_tags")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, err
+		}
+		result[id] = append(result[id], tag)
+	}
+	return result, rows.Err()
+}
+
+// setTaskTags заменяет теги задачи. Новые теги создаются по мере надобности.
+func setTaskTags(tx *db.Tx, taskID interface{}, tags []string) error {
+	start := time.Now()
+	defer metrics.QueryDuration.Since(start, "update_task_tags")
+
+	if _, err := tx.Exec(`DELETE FROM task_tags WHERE task_id = ?`, taskID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec(`INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING`, tag); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO task_tags (task_id, tag_id) VALUES (?, (SELECT id FROM tags WHERE name = ?))`, taskID, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagFilter возвращает условие WHERE для задач, у которых есть все теги из списка.
+func tagFilter(tags []string) (string, []interface{}) {
+	args := make([]interface{}, 0, len(tags)+1)
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+	args = append(args, len(tags))
+	return `id IN (SELECT tt.task_id FROM task_tags tt JOIN tags t ON t.id = tt.tag_id
+		WHERE t.name IN (?` + strings.Repeat(", ?", len(tags)-1) + `)
+		GROUP BY tt.task_id HAVING COUNT(*) = ?)`, args
+}
+
+func TagsHandler(database *db.DB) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := `SELECT t.name, COUNT(*) FROM tags t JOIN task_tags tt ON tt.tag_id = t.id
+			GROUP BY t.name ORDER BY t.name`
+		start := time.Now()
+		rows, err := database.Query(query)
+		metrics.QueryDuration.Since(start, "select_tags")
+		if err != nil {
+			http.Error(w, `{"error":"Ошибка при извлечении тегов из базы данных"}`, http.StatusInternalServerError)
+			log.Println("Ошибка базы данных", err)
+			return
+		}
+		defer rows.Close()
+
+		type tag struct {
+			Name  string `json:"name"`
+			Count int    `json:"count"`
+		}
+		tags := []tag{}
+		for rows.Next() {
+			var t tag
+			if err := rows.Scan(&t.Name, &t.Count); err != nil {
+				http.Error(w, `{"error":"Ошибка при чтении тегов"}`, http.StatusInternalServerError)
+				log.Println("Ошибка при чтении тегов", err)
+				return
+			}
+			tags = append(tags, t)
+		}
+		if err := rows.Err(); err != nil {
+			http.Error(w, `{"error":"Ошибка при чтении тегов"}`, http.StatusInternalServerError)
+			log.Println("Ошибка при чтении тегов", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{"tags": tags})
+	}
+}
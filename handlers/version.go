@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_final_project/db"
+	"go_final_project/metrics"
+)
+
+var errIfMatchRequired = errors.New("не указан заголовок If-Match")
+
+// errWeakETag: If-Match сравнивает теги строго (RFC 9110, 13.1.1),
+// слабый тег не совпадает ни с одной версией.
+var errWeakETag = errors.New("слабый ETag не подходит для If-Match")
+
+func etag(version string) string {
+	return `"` + version + `"`
+}
+
+// ifMatch возвращает версию задачи из заголовка If-Match. nil означает,
+// что версию проверять не нужно: заголовка нет (и он не обязателен) или
+// в нём "*".
+func ifMatch(r *http.Request, required bool) (*int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if required {
+			return nil, errIfMatchRequired
+		}
+		return nil, nil
+	}
+	if header == "*" {
+		return nil, nil
+	}
+	if strings.HasPrefix(header, "W/") {
+		return nil, errWeakETag
+	}
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return nil, errors.New("неверный заголовок If-Match")
+	}
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return nil, errors.New("неверный заголовок If-Match")
+	}
+	return &version, nil
+}
+
+func versionMatches(version *int64, task Task) bool {
+	return version == nil || task.Version == strconv.FormatInt(*version, 10)
+}
+
+func writeIfMatchError(w http.ResponseWriter, err error) {
+	code := http.StatusBadRequest
+	switch {
+	case errors.Is(err, errIfMatchRequired):
+		code = http.StatusPreconditionRequired
+	case errors.Is(err, errWeakETag):
+		code = http.StatusPreconditionFailed
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+func selectTask(q db.Queryer, id interface{}) (Task, error) {
+	var task Task
+	query := `SELECT id, date, title, comment, repeat, priority, version FROM scheduler WHERE id = ?`
+	start := time.Now()
+	err := q.QueryRow(query, id).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Priority, &task.Version)
+	metrics.QueryDuration.Since(start, "select_task")
+	if err != nil {
+		return task, err
+	}
+	task.Tags, err = taskTags(q, task.ID)
+	if err != nil {
+		return task, err
+	}
+	task.Items, err = taskItems(q, task.ID)
+	if err != nil {
+		return task, err
+	}
+	task.BlockedBy, err = taskDeps(q, task.ID)
+	return task, err
+}
+
+// writeConflict отвечает на изменение, которое не затронуло ни одной строки:
+// 404, если задачи больше нет, и 412 с текущей задачей, если изменилась версия.
+// Транзакцию к этому моменту нужно завершить: у SQLite одно соединение.
+func writeConflict(database *db.DB, w http.ResponseWriter, id interface{}) {
+	current, err := selectTask(database, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, `{"error":"Ошибка при извлечении задачи из базы данных"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	writePreconditionFailed(w, current)
+}
+
+func writePreconditionFailed(w http.ResponseWriter, current Task) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("ETag", etag(current.Version))
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": "Задача была изменена другим пользователем",
+		"task":  current,
+	})
+}
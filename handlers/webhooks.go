@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_final_project/db"
+	"go_final_project/metrics"
+	"go_final_project/webhook"
+)
+
+type Subscription struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Secret    string   `json:"secret,omitempty"`
+	Events    []string `json:"events"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// normalizeEvents проверяет события подписки и убирает повторы.
+// Пустой список означает подписку на все события.
+func normalizeEvents(events []string) ([]string, error) {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		if !webhook.ValidEvent(event) {
+			return nil, fmt.Errorf("неизвестное событие %s", event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			result = append(result, event)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// privateWebhookURL отклоняет внутренние адреса сразу при подписке. Имена
+// проверяются при каждой доставке, когда известен адрес (webhook.NewClient).
+func privateWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && webhook.ForbiddenIP(ip)
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func splitEvents(events string) []string {
+	if events == "" {
+		return []string{}
+	}
+	return strings.Split(events, ",")
+}
+
+// WebhooksHandler управляет подписками: GET - список (без секретов),
+// POST - новая подписка, DELETE ?id= - удаление вместе с очередью доставки.
+// allowPrivate разрешает подписчиков во внутренней сети.
+func WebhooksHandler(database *db.DB, allowPrivate bool) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listWebhooks(database, w)
+		case http.MethodPost:
+			createWebhook(database, allowPrivate, w, r)
+		case http.MethodDelete:
+			deleteWebhook(database, w, r)
+		default:
+			http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func listWebhooks(database *db.DB, w http.ResponseWriter) {
+	start := time.Now()
+	rows, err := database.Query(`SELECT id, url, events, created_at FROM webhooks ORDER BY id`)
+	metrics.QueryDuration.Since(start, "select_webhooks")
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при чтении подписок"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	defer rows.Close()
+
+	subs := []Subscription{}
+	for rows.Next() {
+		var s Subscription
+		var events string
+		if err := rows.Scan(&s.ID, &s.URL, &events, &s.CreatedAt); err != nil {
+			http.Error(w, `{"error":"Ошибка при чтении подписок"}`, http.StatusInternalServerError)
+			log.Println("Ошибка при чтении подписок", err)
+			return
+		}
+		s.Events = splitEvents(events)
+		subs = append(subs, s)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, `{"error":"Ошибка при чтении подписок"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при чтении подписок", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"webhooks": subs})
+}
+
+func createWebhook(database *db.DB, allowPrivate bool, w http.ResponseWriter, r *http.Request) {
+	var s Subscription
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, `{"error":"Неверный формат данных"}`, http.StatusBadRequest)
+		return
+	}
+	if !validWebhookURL(s.URL) {
+		http.Error(w, `{"error":"Укажите адрес http:// или https://"}`, http.StatusBadRequest)
+		return
+	}
+	if !allowPrivate && privateWebhookURL(s.URL) {
+		http.Error(w, `{"error":"Адрес во внутренней сети запрещён"}`, http.StatusBadRequest)
+		return
+	}
+	events, err := normalizeEvents(s.Events)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	s.Events = events
+	// Секрет показывается только в ответе на создание подписки.
+	if s.Secret == "" {
+		if s.Secret, err = newSecret(); err != nil {
+			http.Error(w, `{"error":"Ошибка при создании секрета"}`, http.StatusInternalServerError)
+			log.Println("Ошибка при создании секрета", err)
+			return
+		}
+	}
+	s.CreatedAt = time.Now().UTC().Format(webhook.TimeFormat)
+
+	start := time.Now()
+	id, err := database.Insert(`INSERT INTO webhooks (url, secret, events, created_at) VALUES (?, ?, ?, ?)`,
+		s.URL, s.Secret, strings.Join(s.Events, ","), s.CreatedAt)
+	metrics.QueryDuration.Since(start, "insert_webhook")
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при добавлении подписки"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	s.ID = strconv.FormatInt(id, 10)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s)
+}
+
+func deleteWebhook(database *db.DB, w http.ResponseWriter, r *http.Request) {
+	id, ok := queryID(w, r, "id")
+	if !ok {
+		return
+	}
+	start := time.Now()
+	res, err := database.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	metrics.QueryDuration.Since(start, "delete_webhook")
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при удалении подписки"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	if cnt, err := res.RowsAffected(); err == nil && cnt == 0 {
+		http.Error(w, `{"error":"Подписка не найдена"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{}`))
+}
+
+// WebhookDeliveriesHandler - журнал доставки: GET ?webhook_id=&status=&limit=
+// возвращает попытки, начиная с последних; POST ?id= отправляет событие
+// повторно, не дожидаясь следующей попытки.
+func WebhookDeliveriesHandler(database *db.DB) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listDeliveries(database, w, r)
+		case http.MethodPost:
+			retryDelivery(database, w, r)
+		default:
+			http.Error(w, `{"error":"Метод не поддерживается"}`, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func listDeliveries(database *db.DB, w http.ResponseWriter, r *http.Request) {
+	var where []string
+	var args []interface{}
+	params := r.URL.Query()
+
+	if params.Get("webhook_id") != "" {
+		id, ok := queryID(w, r, "webhook_id")
+		if !ok {
+			return
+		}
+		where, args = append(where, "webhook_id = ?"), append(args, id)
+	}
+	if status := params.Get("status"); status != "" {
+		if status != "pending" && status != "delivered" && status != "failed" {
+			http.Error(w, `{"error":"Параметр status должен быть pending, delivered или failed"}`, http.StatusBadRequest)
+			return
+		}
+		where, args = append(where, "status = ?"), append(args, status)
+	}
+
+	limit := 100
+	if l := params.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, `{"error":"Параметр limit должен быть от 1 до 1000"}`, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	query := `SELECT id, webhook_id, event, status, attempts, response_code, last_error,
+		next_attempt_at, created_at, updated_at, payload FROM webhook_deliveries`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	start := time.Now()
+	rows, err := database.Query(query, args...)
+	metrics.QueryDuration.Since(start, "select_webhook_deliveries")
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при чтении журнала доставки"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	defer rows.Close()
+
+	type entry struct {
+		ID            string          `json:"id"`
+		WebhookID     string          `json:"webhook_id"`
+		Event         string          `json:"event"`
+		Status        string          `json:"status"`
+		Attempts      int             `json:"attempts"`
+		ResponseCode  int             `json:"response_code"`
+		LastError     string          `json:"last_error"`
+		NextAttemptAt string          `json:"next_attempt_at"`
+		CreatedAt     string          `json:"created_at"`
+		UpdatedAt     string          `json:"updated_at"`
+		Payload       json.RawMessage `json:"payload"`
+	}
+	deliveries := []entry{}
+	for rows.Next() {
+		var e entry
+		var payload string
+		err := rows.Scan(&e.ID, &e.WebhookID, &e.Event, &e.Status, &e.Attempts, &e.ResponseCode, &e.LastError,
+			&e.NextAttemptAt, &e.CreatedAt, &e.UpdatedAt, &payload)
+		if err != nil {
+			http.Error(w, `{"error":"Ошибка при чтении журнала доставки"}`, http.StatusInternalServerError)
+			log.Println("Ошибка при чтении журнала доставки", err)
+			return
+		}
+		e.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, e)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, `{"error":"Ошибка при чтении журнала доставки"}`, http.StatusInternalServerError)
+		log.Println("Ошибка при чтении журнала доставки", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"deliveries": deliveries})
+}
+
+func retryDelivery(database *db.DB, w http.ResponseWriter, r *http.Request) {
+	id, ok := queryID(w, r, "id")
+	if !ok {
+		return
+	}
+	start := time.Now()
+	res, err := database.Exec(`UPDATE webhook_deliveries SET status = 'pending', next_attempt_at = ?
+		WHERE id = ? AND status <> 'delivered'`, time.Now().UTC().Format(webhook.TimeFormat), id)
+	metrics.QueryDuration.Since(start, "retry_webhook_delivery")
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка при повторной отправке"}`, http.StatusInternalServerError)
+		log.Println("Ошибка базы данных", err)
+		return
+	}
+	if cnt, err := res.RowsAffected(); err == nil && cnt == 0 {
+		http.Error(w, `{"error":"Недоставленное событие не найдено"}`, http.StatusNotFound)
+		return
+	}
+	webhook.Wake()
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{}`))
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go_final_project/db"
+	"go_final_project/events"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsMaxMessage   = 1 << 20
+)
+
+// originKey - ключ контекста с идентификатором соединения WebSocket,
+// через которое пришло изменение (см. taskChanged).
+type originKey struct{}
+
+// wsMessage - сообщение клиента. id клиент выбирает сам и получает его
+// обратно в ack или error.
+type wsMessage struct {
+	ID          string          `json:"id,omitempty"`
+	Type        string          `json:"type"`
+	Task        json.RawMessage `json:"task,omitempty"`
+	TaskID      json.Number     `json:"task_id,omitempty"`
+	Version     json.Number     `json:"version,omitempty"`
+	Force       bool            `json:"force,omitempty"`
+	LastEventID int64           `json:"last_event_id,omitempty"`
+}
+
+// wsReply - сообщение сервера: ack, error, event или reset. В result
+// тот же JSON, что вернул бы соответствующий HTTP-запрос.
+type wsReply struct {
+	ID     string          `json:"id,omitempty"`
+	Type   string          `json:"type"`
+	Status int             `json:"status,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Event  *events.Event   `json:"event,omitempty"`
+}
+
+// responseBuffer принимает ответ обработчика задачи, вызванного из WebSocket.
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+type wsConn struct {
+	id   string
+	conn *websocket.Conn
+	r    *http.Request
+
+	writeMu sync.Mutex
+
+	subMu sync.Mutex
+	sub   *events.Subscription
+}
+
+var (
+	wsMu    sync.Mutex
+	wsConns = map[*wsConn]struct{}{}
+	wsSeq   atomic.Int64
+)
+
+// CloseWebSockets закрывает открытые соединения при остановке сервера:
+// Shutdown не ждёт соединений, перехваченных у net/http.
+func CloseWebSockets() {
+	wsMu.Lock()
+	defer wsMu.Unlock()
+	for c := range wsConns {
+		c.writeMu.Lock()
+		c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "сервер останавливается"),
+			time.Now().Add(time.Second))
+		c.writeMu.Unlock()
+		c.conn.Close()
+	}
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// WebSocketHandler - двусторонняя синхронизация задач. Клиент отправляет
+// subscribe, create, update, done и delete; изменения выполняются теми же
+// обработчиками, что и HTTP API, а другие подписанные клиенты получают
+// события из events.Default.
+func WebSocketHandler(database *db.DB, requireIfMatch bool) func(w http.ResponseWriter, r *http.Request) {
+	task := TaskHandler(database, requireIfMatch)
+	done := MarkTaskDoneHandler(database, requireIfMatch)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c := &wsConn{id: "ws-" + strconv.FormatInt(wsSeq.Add(1), 10), conn: conn, r: r}
+
+		wsMu.Lock()
+		wsConns[c] = struct{}{}
+		wsMu.Unlock()
+		defer func() {
+			wsMu.Lock()
+			delete(wsConns, c)
+			wsMu.Unlock()
+			c.unsubscribe()
+			conn.Close()
+		}()
+
+		conn.SetReadLimit(wsMaxMessage)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+
+		stop := make(chan struct{})
+		defer close(stop)
+		go c.ping(stop)
+
+		for {
+			var msg wsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				var syntaxErr *json.SyntaxError
+				var typeErr *json.UnmarshalTypeError
+				if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+					c.send(wsReply{Type: "error", Status: http.StatusBadRequest, Error: "Неверный формат сообщения"})
+					continue
+				}
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+			if msg.Type == "subscribe" {
+				err = c.subscribe(msg)
+			} else {
+				err = c.send(c.handle(msg, task, done))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConn) send(reply wsReply) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteJSON(reply)
+}
+
+func (c *wsConn) ping(stop <-chan struct{}) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			c.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConn) handle(msg wsMessage, task, done http.HandlerFunc) wsReply {
+	switch msg.Type {
+	case "create":
+		return c.call(msg, task, http.MethodPost, "/api/task", msg.Task)
+	case "update":
+		return c.call(msg, task, http.MethodPut, "/api/task", msg.Task)
+	case "done":
+		target := "/api/task/done?id=" + url.QueryEscape(msg.TaskID.String())
+		if msg.Force {
+			target += "&force=true"
+		}
+		return c.call(msg, done, http.MethodPost, target, nil)
+	case "delete":
+		return c.call(msg, task, http.MethodDelete, "/api/task?id="+url.QueryEscape(msg.TaskID.String()), nil)
+	default:
+		return wsReply{ID: msg.ID, Type: "error", Status: http.StatusBadRequest,
+			Error: fmt.Sprintf("Неизвестный тип сообщения: %s", msg.Type)}
+	}
+}
+
+// call выполняет обработчик HTTP API от имени клиента WebSocket и
+// превращает его ответ в ack или error.
+func (c *wsConn) call(msg wsMessage, h http.HandlerFunc, method, target string, body []byte) wsReply {
+	ctx := WithActor(context.WithValue(c.r.Context(), originKey{}, c.id), actor(c.r))
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return wsReply{ID: msg.ID, Type: "error", Status: http.StatusBadRequest, Error: "Некорректный запрос"}
+	}
+	req.RemoteAddr = c.r.RemoteAddr
+	req.Header.Set("Content-Type", "application/json")
+	if msg.Version != "" {
+		req.Header.Set("If-Match", etag(msg.Version.String()))
+	}
+
+	resp := &responseBuffer{header: http.Header{}}
+	h(resp, req)
+
+	reply := wsReply{ID: msg.ID, Type: "ack", Status: resp.status}
+	result := bytes.TrimSpace(resp.body.Bytes())
+	if json.Valid(result) {
+		reply.Result = result
+	}
+	if resp.status >= http.StatusBadRequest {
+		reply.Type = "error"
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(result, &e) != nil || e.Error == "" {
+			e.Error = http.StatusText(resp.status)
+		}
+		reply.Error = e.Error
+	}
+	return reply
+}
+
+// subscribe подписывает соединение на события после last_event_id и
+// отвечает ack, а если пропущенное восстановить нельзя - reset. Собственные
+// изменения клиента не пересылаются: о них он узнаёт из ack.
+func (c *wsConn) subscribe(msg wsMessage) error {
+	c.unsubscribe()
+	sub := events.Default.Subscribe(msg.LastEventID, func(e events.Event) bool {
+		return e.Origin != c.id
+	})
+	c.subMu.Lock()
+	c.sub = sub
+	c.subMu.Unlock()
+
+	reply := wsReply{ID: msg.ID, Type: "ack"}
+	if sub.Reset {
+		reply.Type = "reset"
+	}
+	if err := c.send(reply); err != nil {
+		return err
+	}
+
+	go func() {
+		for e := range sub.Events {
+			if err := c.send(wsReply{Type: "event", Event: &e}); err != nil {
+				return
+			}
+		}
+		// Подписку закрыл хаб (клиент не успевал читать или сервер
+		// останавливается), а не новый subscribe: разрываем соединение,
+		// клиент переподключится с last_event_id.
+		c.subMu.Lock()
+		current := c.sub == sub
+		c.subMu.Unlock()
+		if current {
+			c.conn.Close()
+		}
+	}()
+	return nil
+}
+
+func (c *wsConn) unsubscribe() {
+	c.subMu.Lock()
+	sub := c.sub
+	c.sub = nil
+	c.subMu.Unlock()
+	if sub != nil {
+		events.Default.Unsubscribe(sub)
+	}
+}
@@ -2,45 +2,59 @@ package main
 
 import (
 	"fmt"
-	"log"
-	"net/http"
 	"os"
-	"strconv"
-
-	"go_final_project/config"
-	"go_final_project/db"
-	"go_final_project/handlers"
+	"strings"
 )
 
-func main() {
-	webDir := "web"
-
-	port := os.Getenv("TODO_PORT")
-	if port == "" {
-		port = strconv.Itoa(config.Port)
-	}
-	port = ":" + port
-
-	database, err := db.InitDB()
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer database.Close()
-
-	fmt.Println("Сервер запущен на порту", port)
-
-	http.HandleFunc("/api/task/done", handlers.MarkTaskDoneHandler(database))
+const usage = `Использование: %s [команда] [флаги]
 
-	http.HandleFunc("/api/task", handlers.TaskHandler(database))
+Команды:
+  serve    запустить веб-сервер (по умолчанию)
+  migrate  применить миграции схемы
+  backup   сделать резервную копию базы: backup [-o ФАЙЛ]
+  restore  восстановить базу из копии (сервер должен быть остановлен): restore ФАЙЛ или restore -latest
+  vacuum   сжать файл базы данных
+  check    проверить целостность базы и версию схемы
+  export   выгрузить задачи: export [-format json|csv] [-o ФАЙЛ]
+  holidays загрузить производственный календарь: holidays [-replace] ФАЙЛ
 
-	http.HandleFunc("/api/tasks", handlers.GetTasksHandler(database))
+Флаги конфигурации (-config, -port, -db и др.) принимаются всеми командами.
+`
 
-	http.HandleFunc("/api/nextdate", handlers.NextDateHandler)
+func main() {
+	args := os.Args[1:]
+	cmd := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
 
-	http.Handle("/", http.FileServer(http.Dir(webDir)))
+	var err error
+	switch cmd {
+	case "serve":
+		serve(args)
+	case "migrate":
+		err = migrateCmd(args)
+	case "backup":
+		err = backupCmd(args)
+	case "restore":
+		err = restoreCmd(args)
+	case "vacuum":
+		err = vacuumCmd(args)
+	case "check":
+		err = checkCmd(args)
+	case "export":
+		err = exportCmd(args)
+	case "holidays":
+		err = holidaysCmd(args)
+	case "help":
+		fmt.Printf(usage, os.Args[0])
+	default:
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		os.Exit(2)
+	}
 
-	err = http.ListenAndServe(port, nil)
 	if err != nil {
-		log.Fatal("Ошибка при запуске сервера: ", err)
+		fmt.Fprintln(os.Stderr, "Ошибка:", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,227 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+var (
+	Requests = NewCounter("scheduler_http_requests_total",
+		"Количество HTTP-запросов", "route", "method", "code")
+	RequestDuration = NewHistogram("scheduler_http_request_duration_seconds",
+		"Время обработки HTTP-запросов", DefaultBuckets, "route", "method")
+	QueryDuration = NewHistogram("scheduler_db_query_duration_seconds",
+		"Время выполнения запросов к базе данных", DefaultBuckets, "query")
+	Tasks = NewGauge("scheduler_tasks",
+		"Количество задач по состоянию", "state")
+	NextDateErrors = NewCounter("scheduler_nextdate_errors_total",
+		"Количество ошибок разбора правил повторения")
+	Reminders = NewCounter("scheduler_reminders_total",
+		"Количество отправленных напоминаний по результату", "result")
+	WebhookDeliveries = NewCounter("scheduler_webhook_deliveries_total",
+		"Количество попыток доставки вебхуков по итоговому статусу", "status")
+)
+
+var registry = []collector{Requests, RequestDuration, QueryDuration, Tasks, NextDateErrors, Reminders, WebhookDeliveries}
+
+type collector interface {
+	write(w io.Writer) error
+}
+
+type series struct {
+	labels  []string
+	value   float64
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newVec(name, help, kind string, labels []string) vec {
+	return vec{name: name, help: help, kind: kind, labels: labels, series: map[string]*series{}}
+}
+
+func (v *vec) get(values []string, buckets int) *series {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s ожидает %d меток, передано %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), values...), buckets: make([]uint64, buckets)}
+		v.series[key] = s
+	}
+	return s
+}
+
+func (v *vec) sorted() []*series {
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make([]*series, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, v.series[k])
+	}
+	return res
+}
+
+func (v *vec) header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+	return err
+}
+
+type Counter struct{ vec }
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{newVec(name, help, "counter", labels)}
+}
+
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *Counter) Add(delta float64, values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(values, 0).value += delta
+}
+
+func (c *Counter) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.header(w); err != nil {
+		return err
+	}
+	if len(c.labels) == 0 && len(c.series) == 0 {
+		c.get(nil, 0)
+	}
+	for _, s := range c.sorted() {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, s.labels), formatFloat(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type Gauge struct{ vec }
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{newVec(name, help, "gauge", labels)}
+}
+
+func (g *Gauge) Set(value float64, values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(values, 0).value = value
+}
+
+func (g *Gauge) write(w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.header(w); err != nil {
+		return err
+	}
+	for _, s := range g.sorted() {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labels, s.labels), formatFloat(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type Histogram struct {
+	vec
+	bounds []float64
+}
+
+func NewHistogram(name, help string, bounds []float64, labels ...string) *Histogram {
+	return &Histogram{vec: newVec(name, help, "histogram", labels), bounds: bounds}
+}
+
+func (h *Histogram) Observe(value float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(values, len(h.bounds))
+	for i, b := range h.bounds {
+		if value <= b {
+			s.buckets[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *Histogram) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.header(w); err != nil {
+		return err
+	}
+	names := append(append([]string(nil), h.labels...), "le")
+	for _, s := range h.sorted() {
+		for i, b := range h.bounds {
+			values := append(append([]string(nil), s.labels...), formatFloat(b))
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(names, values), s.buckets[i]); err != nil {
+				return err
+			}
+		}
+		values := append(append([]string(nil), s.labels...), "+Inf")
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(names, values), s.count); err != nil {
+			return err
+		}
+		labels := formatLabels(h.labels, s.labels)
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", h.name, labels, formatFloat(s.sum), h.name, labels, s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func Write(w io.Writer) error {
+	for _, c := range registry {
+		if err := c.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
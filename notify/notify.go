@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Reminder - напоминание о задаче, срок которой наступил или скоро наступит.
+type Reminder struct {
+	TaskID   string `json:"id"`
+	Date     string `json:"date"`
+	Title    string `json:"title"`
+	Comment  string `json:"comment"`
+	Repeat   string `json:"repeat"`
+	Priority string `json:"priority"`
+}
+
+func (r Reminder) Subject() string {
+	return fmt.Sprintf("Напоминание: %s (%s)", r.Title, r.Date)
+}
+
+func (r Reminder) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Задача: %s\n", r.Title)
+	fmt.Fprintf(&b, "Дата: %s\n", r.Date)
+	if r.Priority != "" {
+		fmt.Fprintf(&b, "Приоритет: %s\n", r.Priority)
+	}
+	if r.Repeat != "" {
+		fmt.Fprintf(&b, "Повтор: %s\n", r.Repeat)
+	}
+	if r.Comment != "" {
+		fmt.Fprintf(&b, "\n%s\n", r.Comment)
+	}
+	return b.String()
+}
+
+// Notifier доставляет напоминание получателю. Ошибка означает, что
+// напоминание не доставлено и его нужно повторить позже.
+type Notifier interface {
+	Notify(ctx context.Context, r Reminder) error
+}
+
+// Multi отправляет напоминание через все способы сразу. Напоминание
+// считается доставленным, если сработал хотя бы один из них: иначе
+// исправные способы повторяли бы его при каждой проверке. Ошибки
+// остальных только пишутся в журнал.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, r Reminder) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == len(m) {
+		return errors.Join(errs...)
+	}
+	for _, err := range errs {
+		log.Printf("Напоминание о задаче %s доставлено не всеми способами: %v", r.TaskID, err)
+	}
+	return nil
+}
+
+// Writer пишет напоминания в W по одному JSON-объекту в строке.
+type Writer struct {
+	W io.Writer
+
+	mu     sync.Mutex
+	closer io.Closer
+}
+
+// OpenFile открывает файл для дописывания напоминаний; "-" - стандартный вывод.
+func OpenFile(path string) (*Writer, error) {
+	if path == "-" {
+		return &Writer{W: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{W: f, closer: f}, nil
+}
+
+func (w *Writer) Notify(ctx context.Context, r Reminder) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.W.Write(append(data, '\n'))
+	return err
+}
+
+func (w *Writer) Close() error {
+	if w.closer == nil {
+		return nil
+	}
+	return w.closer.Close()
+}
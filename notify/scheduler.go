@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go_final_project/db"
+	"go_final_project/metrics"
+)
+
+const dateFormat = "20060102"
+
+// Scheduler ищет задачи, срок которых наступает сегодня или в пределах
+// Lead, и отправляет по каждой одно напоминание на дату. Отправленные
+// напоминания записываются в reminders_sent; недоставленные повторяются
+// при следующей проверке.
+type Scheduler struct {
+	DB       *db.DB
+	Notifier Notifier
+	Lead     time.Duration
+	// Now подменяется в тестах; по умолчанию time.Now.
+	Now func() time.Time
+}
+
+func (s *Scheduler) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// due возвращает задачи с датой от from до to включительно, по которым
+// напоминание ещё не отправлено.
+func (s *Scheduler) due(ctx context.Context, from, to string) ([]Reminder, error) {
+	start := time.Now()
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, date, title, comment, repeat, priority FROM scheduler
+		WHERE date >= ? AND date <= ?
+		AND NOT EXISTS (SELECT 1 FROM reminders_sent r WHERE r.task_id = scheduler.id AND r.date = scheduler.date)
+		ORDER BY date, id`, from, to)
+	metrics.QueryDuration.Since(start, "select_due_tasks")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []Reminder
+	for rows.Next() {
+		var r Reminder
+		if err := rows.Scan(&r.TaskID, &r.Date, &r.Title, &r.Comment, &r.Repeat, &r.Priority); err != nil {
+			return nil, err
+		}
+		due = append(due, r)
+	}
+	return due, rows.Err()
+}
+
+// Check отправляет напоминания по наступившим задачам и возвращает,
+// сколько из них доставлено.
+func (s *Scheduler) Check(ctx context.Context) (int, error) {
+	now := s.now()
+	due, err := s.due(ctx, now.Format(dateFormat), now.Add(s.Lead).Format(dateFormat))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при поиске задач для напоминаний: %w", err)
+	}
+
+	sent := 0
+	for _, r := range due {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		if err := s.Notifier.Notify(ctx, r); err != nil {
+			metrics.Reminders.Inc("error")
+			log.Printf("Ошибка при отправке напоминания о задаче %s: %v", r.TaskID, err)
+			continue
+		}
+		metrics.Reminders.Inc("sent")
+		_, err := s.DB.ExecContext(ctx, `INSERT INTO reminders_sent (task_id, date, sent_at) VALUES (?, ?, ?)
+			ON CONFLICT (task_id, date) DO NOTHING`,
+			r.TaskID, r.Date, now.UTC().Format("2006-01-02T15:04:05Z"))
+		if err != nil {
+			return sent, fmt.Errorf("ошибка при сохранении напоминания: %w", err)
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Check(ctx); err != nil && ctx.Err() == nil {
+			log.Println("Ошибка при проверке напоминаний: ", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Webhook отправляет напоминание POST-запросом с JSON
+// {"event":"reminder","task":{...}}. Успехом считается любой ответ 2xx.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (h *Webhook) Notify(ctx context.Context, r Reminder) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"event": "reminder",
+		"task":  r,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка при отправке напоминания на %s: %w", h.URL, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s ответил %s", h.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"go_final_project/backup"
+	"go_final_project/bot"
+	"go_final_project/calendar"
+	"go_final_project/config"
+	"go_final_project/db"
+	"go_final_project/events"
+	"go_final_project/handlers"
+	"go_final_project/metrics"
+	"go_final_project/notify"
+	"go_final_project/webhook"
+)
+
+func serve(args []string) {
+	cfg, err := config.Load(flag.NewFlagSet("serve", flag.ExitOnError), args)
+	if err != nil {
+		log.Fatal("Ошибка конфигурации: ", err)
+	}
+	port := ":" + strconv.Itoa(cfg.Port)
+
+	database, err := db.InitDB(cfg.Database(), cfg.DBOptions())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := calendar.Default.Load(database); err != nil {
+		log.Fatal(err)
+	}
+
+	backups := &backup.Manager{
+		DB:     database,
+		Dir:    cfg.BackupDir,
+		Keep:   cfg.BackupKeep,
+		MaxAge: cfg.BackupMaxAge,
+	}
+
+	notifier, closeNotifier, err := reminderNotifier(cfg)
+	if err != nil {
+		log.Fatal("Ошибка настройки напоминаний: ", err)
+	}
+	defer closeNotifier()
+	reminders := &notify.Scheduler{
+		DB:       database,
+		Notifier: notifier,
+		Lead:     cfg.ReminderLead,
+	}
+
+	dispatcher := &webhook.Dispatcher{
+		DB:          database,
+		Client:      webhook.NewClient(cfg.WebhookAllowPrivate),
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		RetryDelay:  cfg.WebhookRetryDelay,
+		MaxDelay:    time.Hour,
+	}
+
+	proxies, err := cfg.TrustedProxyNets()
+	if err != nil {
+		log.Fatal("Ошибка конфигурации: ", err)
+	}
+	handlers.SetTrustedProxies(proxies)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/task/items", metrics.Instrument("/api/task/items", handlers.TaskItemsHandler(database, cfg.RequireIfMatch)))
+
+	mux.HandleFunc("/api/task/done", metrics.Instrument("/api/task/done", handlers.MarkTaskDoneHandler(database, cfg.RequireIfMatch)))
+
+	mux.HandleFunc("/api/task", metrics.Instrument("/api/task", handlers.TaskHandler(database, cfg.RequireIfMatch)))
+
+	mux.HandleFunc("/api/tasks", metrics.Instrument("/api/tasks", handlers.GetTasksHandler(database)))
+
+	mux.HandleFunc("/api/tags", metrics.Instrument("/api/tags", handlers.TagsHandler(database)))
+
+	mux.HandleFunc("/api/webhooks/deliveries", metrics.Instrument("/api/webhooks/deliveries", handlers.Admin(cfg.AdminToken, handlers.WebhookDeliveriesHandler(database))))
+
+	mux.HandleFunc("/api/webhooks", metrics.Instrument("/api/webhooks", handlers.Admin(cfg.AdminToken, handlers.WebhooksHandler(database, cfg.WebhookAllowPrivate))))
+
+	mux.HandleFunc("/api/events", handlers.EventsHandler(events.Default))
+
+	mux.HandleFunc("/api/ws", handlers.WebSocketHandler(database, cfg.RequireIfMatch))
+
+	mux.HandleFunc("/api/audit", metrics.Instrument("/api/audit", handlers.AuditHandler(database)))
+
+	mux.HandleFunc("/api/nextdate", metrics.Instrument("/api/nextdate", handlers.NextDateHandler))
+	mux.HandleFunc("/api/parse", metrics.Instrument("/api/parse", handlers.ParseHandler))
+	mux.HandleFunc("/api/repeat", metrics.Instrument("/api/repeat", handlers.RepeatHandler))
+	mux.HandleFunc("/api/holidays/import", metrics.Instrument("/api/holidays/import", handlers.Admin(cfg.AdminToken, handlers.HolidaysImportHandler(database))))
+	mux.HandleFunc("/api/holidays", metrics.Instrument("/api/holidays", handlers.AdminWrites(cfg.AdminToken, handlers.HolidaysHandler(database))))
+
+	mux.HandleFunc("/api/admin/backup", metrics.Instrument("/api/admin/backup", handlers.Admin(cfg.AdminToken, handlers.BackupHandler(backups))))
+
+	mux.HandleFunc("/metrics", handlers.MetricsHandler(database))
+
+	mux.HandleFunc("/healthz", handlers.HealthHandler)
+
+	mux.HandleFunc("/readyz", handlers.ReadyHandler(database, cfg.WebDir))
+
+	mux.Handle("/", metrics.Instrument("/", http.FileServer(http.Dir(cfg.WebDir)).ServeHTTP))
+
+	var handler http.Handler = mux
+	if cfg.TLS() {
+		handler = handlers.SecureHeaders(mux, cfg.HSTSMaxAge)
+	}
+
+	server := &http.Server{
+		Addr:         port,
+		Handler:      handler,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+
+	server.RegisterOnShutdown(events.Default.Close)
+	server.RegisterOnShutdown(handlers.CloseWebSockets)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var background sync.WaitGroup
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		calendar.Default.Run(ctx, database, calendar.ReloadInterval)
+	}()
+	if cfg.BackupInterval > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			backups.Run(ctx, cfg.BackupInterval)
+		}()
+	}
+	if cfg.ReminderInterval > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			reminders.Run(ctx, cfg.ReminderInterval)
+		}()
+	}
+	if cfg.BotToken != "" {
+		chats, err := cfg.BotChatIDs()
+		if err != nil {
+			log.Fatal("Ошибка конфигурации: ", err)
+		}
+		telegram := &bot.Bot{
+			Transport:    &bot.Telegram{BaseURL: cfg.BotAPIURL, Token: cfg.BotToken, PollTimeout: 30 * time.Second},
+			API:          mux,
+			AllowedChats: chats,
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			telegram.Run(ctx)
+		}()
+	}
+	if cfg.WebhookInterval > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			dispatcher.Run(ctx, cfg.WebhookInterval)
+		}()
+	}
+
+	serverErr := make(chan error, 2)
+	go func() {
+		if cfg.TLS() {
+			server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			fmt.Println("Сервер запущен на порту", port, "(HTTPS)")
+			serverErr <- server.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+			return
+		}
+		fmt.Println("Сервер запущен на порту", port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	var redirectServer *http.Server
+	if cfg.TLS() && cfg.RedirectPort != 0 {
+		redirectServer = &http.Server{
+			Addr:         ":" + strconv.Itoa(cfg.RedirectPort),
+			Handler:      http.HandlerFunc(handlers.RedirectToHTTPS(strconv.Itoa(cfg.Port))),
+			ReadTimeout:  server.ReadTimeout,
+			WriteTimeout: server.WriteTimeout,
+			IdleTimeout:  server.IdleTimeout,
+		}
+		go func() {
+			fmt.Println("Перенаправление HTTP -> HTTPS на порту", redirectServer.Addr)
+			if err := redirectServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}()
+	}
+
+	select {
+	case err = <-serverErr:
+		database.Close()
+		log.Fatal("Ошибка при запуске сервера: ", err)
+	case <-ctx.Done():
+	}
+
+	// Сначала /readyz начинает отвечать 503, и балансировщик перестаёт слать
+	// новые запросы, а сервер пока принимает те, что уже в пути.
+	handlers.SetShuttingDown()
+	fmt.Println("Сервер останавливается")
+	time.Sleep(cfg.ShutdownDrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(shutdownCtx); err != nil {
+			log.Println("Ошибка при остановке сервера перенаправления: ", err)
+		}
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Ошибка при остановке сервера: ", err)
+	}
+	if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Println("Ошибка сервера: ", err)
+	}
+
+	background.Wait()
+	if err := database.Close(); err != nil {
+		log.Println("Ошибка при закрытии базы данных: ", err)
+	}
+	fmt.Println("Сервер остановлен")
+}
+
+// reminderNotifier собирает способы доставки напоминаний из конфигурации.
+// Если ни один не настроен, напоминания пишутся в стандартный вывод.
+func reminderNotifier(cfg config.Config) (notify.Notifier, func(), error) {
+	var notifiers notify.Multi
+	closeFn := func() {}
+	if cfg.ReminderFile != "" {
+		w, err := notify.OpenFile(cfg.ReminderFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		notifiers = append(notifiers, w)
+		closeFn = func() { w.Close() }
+	}
+	if cfg.ReminderWebhook != "" {
+		notifiers = append(notifiers, &notify.Webhook{URL: cfg.ReminderWebhook})
+	}
+	if cfg.SMTPAddr != "" {
+		notifiers = append(notifiers, &notify.SMTP{
+			Addr:     cfg.SMTPAddr,
+			From:     cfg.SMTPFrom,
+			To:       cfg.SMTPRecipients(),
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+		})
+	}
+	if len(notifiers) == 0 {
+		notifiers = append(notifiers, &notify.Writer{W: os.Stdout})
+	}
+	if len(notifiers) == 1 {
+		return notifiers[0], closeFn, nil
+	}
+	return notifiers, closeFn, nil
+}
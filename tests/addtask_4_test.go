@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/cookiejar"
+	"os"
 	"strconv"
 	"testing"
 	"time"
@@ -35,6 +36,9 @@ func requestJSON(apipath string, values map[string]any, method string) ([]byte,
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if token := os.Getenv("TODO_ADMIN_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 
 	client := &http.Client{}
 	if len(config.Token) > 0 {
@@ -138,7 +142,7 @@ func TestAddTask(t *testing.T) {
 			}
 			id := fmt.Sprint(mid)
 
-			err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+			err = db.Get(&task, db.Rebind(`SELECT * FROM scheduler WHERE id=?`), id)
 			assert.NoError(t, err)
 			assert.Equal(t, id, strconv.FormatInt(task.ID, 10))
 
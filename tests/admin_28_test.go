@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go_final_project/backup"
+	"go_final_project/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildApp собирает сервер во временную директорию.
+func buildApp(t *testing.T) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "app")
+	out, err := exec.Command("go", "build", "-o", bin, "..").CombinedOutput()
+	require.NoError(t, err, string(out))
+	return bin
+}
+
+// runApp запускает команду сервера в отдельной директории, не подхватывая
+// конфигурацию и базу из окружения.
+func runApp(t *testing.T, bin string, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(bin, args...)
+	cmd.Dir = t.TempDir()
+	cmd.Env = append(os.Environ(), "TODO_CONFIG=", "TODO_DSN=", "TODO_DBFILE=")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// newOldSchema создаёт базу в том виде, в каком её оставляла первая миграция.
+func newOldSchema(t *testing.T, path string) {
+	t.Helper()
+	database, err := db.Open(path, db.Options{BusyTimeout: time.Second})
+	require.NoError(t, err)
+	defer database.Close()
+	for _, query := range []string{
+		`CREATE TABLE scheduler (id INTEGER PRIMARY KEY AUTOINCREMENT, date TEXT NOT NULL, title TEXT NOT NULL, comment TEXT, repeat TEXT)`,
+		`CREATE INDEX scheduler_date ON scheduler (date)`,
+		`INSERT INTO scheduler (date, title, comment, repeat) VALUES ('20240101', 'Старая задача', 'комментарий', 'd 1')`,
+		`PRAGMA user_version = 1`,
+	} {
+		_, err := database.Exec(query)
+		require.NoError(t, err)
+	}
+}
+
+func TestAdminExport(t *testing.T) {
+	bin := buildApp(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scheduler.db")
+	newOldSchema(t, path)
+
+	// check ничего не меняет и подсказывает, что делать со старой схемой.
+	out, err := runApp(t, bin, "check", "-db", path)
+	require.Error(t, err)
+	assert.Contains(t, out, "выполните команду migrate")
+
+	out, err = runApp(t, bin, "export", "-db", path, "-o", filepath.Join(dir, "tasks.json"))
+	require.NoError(t, err, out)
+	data, err := os.ReadFile(filepath.Join(dir, "tasks.json"))
+	require.NoError(t, err)
+	var exported struct {
+		Tasks []db.Task `json:"tasks"`
+	}
+	require.NoError(t, json.Unmarshal(data, &exported))
+	require.Len(t, exported.Tasks, 1)
+	assert.Equal(t, "Старая задача", exported.Tasks[0].Title)
+	assert.Equal(t, "комментарий", exported.Tasks[0].Comment)
+	assert.Equal(t, "d 1", exported.Tasks[0].Repeat)
+
+	// После выгрузки схема обновлена.
+	out, err = runApp(t, bin, "check", "-db", path)
+	require.NoError(t, err, out)
+
+	out, err = runApp(t, bin, "export", "-db", path, "-format", "csv", "-o", filepath.Join(dir, "tasks.csv"))
+	require.NoError(t, err, out)
+	f, err := os.Open(filepath.Join(dir, "tasks.csv"))
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, []string{"id", "date", "title", "comment", "repeat", "priority"}, records[0])
+	assert.Equal(t, []string{"20240101", "Старая задача", "комментарий", "d 1"}, records[1][1:5])
+
+	out, err = runApp(t, bin, "export", "-db", path, "-format", "xml")
+	require.Error(t, err)
+	assert.Contains(t, out, "неизвестный формат")
+}
+
+func TestAdminRestoreLatest(t *testing.T) {
+	bin := buildApp(t)
+	dir := t.TempDir()
+	backups := filepath.Join(dir, "backups")
+	target := filepath.Join(dir, "scheduler.db")
+
+	// Без копий восстанавливать нечего.
+	out, err := runApp(t, bin, "restore", "-latest", "-db", target, "-backup-dir", backups)
+	require.Error(t, err, out)
+
+	for _, title := range []string{"old", "new"} {
+		source := filepath.Join(dir, title+".db")
+		database, err := db.Open(source, db.Options{BusyTimeout: time.Second})
+		require.NoError(t, err)
+		require.NoError(t, db.Migrate(database))
+		_, err = database.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, '', '')`, "20240101", title)
+		require.NoError(t, err)
+		if title == "old" {
+			_, err = (&backup.Manager{DB: database, Dir: backups}).Snapshot()
+			require.NoError(t, err)
+			require.NoError(t, database.Close())
+		} else {
+			// backup без -o кладёт снимок туда же, где его ищет restore -latest.
+			require.NoError(t, database.Close())
+			out, err := runApp(t, bin, "backup", "-db", source, "-backup-dir", backups)
+			require.NoError(t, err, out)
+			assert.Contains(t, out, filepath.Join(backups, "scheduler-"))
+		}
+		// Имена копий различаются по миллисекундам.
+		time.Sleep(10 * time.Millisecond)
+	}
+	newSnapshot(t, dir, "current")
+	require.NoError(t, os.Rename(filepath.Join(dir, "current.db"), target))
+
+	out, err = runApp(t, bin, "restore", "-latest", "-db", target, "-backup-dir", backups)
+	require.NoError(t, err, out)
+	assert.True(t, strings.Contains(out, backups), out)
+	assert.Equal(t, []string{"new"}, taskTitles(t, target))
+
+	out, err = runApp(t, bin, "restore", "-db", target)
+	require.Error(t, err)
+	assert.Contains(t, out, "нужно указать файл резервной копии или -latest")
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	appdb "go_final_project/db"
+	"go_final_project/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type auditEntry struct {
+	ID        string `json:"id"`
+	TaskID    string `json:"task_id"`
+	Actor     string `json:"actor"`
+	Action    string `json:"action"`
+	CreatedAt string `json:"created_at"`
+	Diff      map[string]struct {
+		Before *string `json:"before"`
+		After  *string `json:"after"`
+	} `json:"diff"`
+}
+
+func getAudit(t *testing.T, query string) []auditEntry {
+	t.Helper()
+	body, err := requestJSON("api/audit?"+query, nil, http.MethodGet)
+	require.NoError(t, err)
+	var resp struct {
+		Entries []auditEntry `json:"entries"`
+		Error   string       `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(body, &resp), string(body))
+	require.Empty(t, resp.Error)
+	return resp.Entries
+}
+
+func TestAudit(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now().Format(`20060102`)
+	m, err := postJSON("api/task", map[string]any{
+		"date":  now,
+		"title": "Аудит",
+	}, http.MethodPost)
+	require.NoError(t, err)
+	require.NotNil(t, m["id"])
+	id := fmt.Sprint(m["id"])
+
+	m, err = postJSON("api/task", map[string]any{
+		"id":      id,
+		"date":    now,
+		"title":   "Аудит (изм.)",
+		"comment": "",
+		"repeat":  "",
+	}, http.MethodPut)
+	require.NoError(t, err)
+	require.Empty(t, m["error"])
+
+	m, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	require.NoError(t, err)
+	require.Empty(t, m)
+
+	entries := getAudit(t, "task_id="+id)
+	require.Len(t, entries, 3)
+	for i, action := range []string{"done", "update", "create"} {
+		assert.Equal(t, action, entries[i].Action)
+		assert.Equal(t, id, entries[i].TaskID)
+		assert.NotEmpty(t, entries[i].Actor)
+		assert.NotEmpty(t, entries[i].CreatedAt)
+	}
+
+	title := entries[1].Diff["title"]
+	require.NotNil(t, title.Before)
+	require.NotNil(t, title.After)
+	assert.Equal(t, "Аудит", *title.Before)
+	assert.Equal(t, "Аудит (изм.)", *title.After)
+	assert.NotContains(t, entries[1].Diff, "date")
+
+	assert.Nil(t, entries[2].Diff["title"].Before)
+	assert.Nil(t, entries[0].Diff["title"].After)
+
+	yesterday := time.Now().AddDate(0, 0, -1).Format(`20060102`)
+	assert.Empty(t, getAudit(t, "task_id="+id+"&to="+yesterday))
+	assert.Len(t, getAudit(t, "task_id="+id+"&from="+now+"&to="+now), 3)
+
+	body, err := requestJSON("api/audit?from=вчера", nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "error")
+
+	// Журнал только пополняется.
+	_, err = db.Exec(db.Rebind(`DELETE FROM audit_log WHERE task_id = ?`), id)
+	assert.Error(t, err)
+}
+
+func TestAuditActor(t *testing.T) {
+	database, err := appdb.Open(filepath.Join(t.TempDir(), "audit.db"), appdb.Options{BusyTimeout: time.Second})
+	require.NoError(t, err)
+	defer database.Close()
+	require.NoError(t, appdb.Migrate(database))
+	_, proxy, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+	handlers.SetTrustedProxies([]*net.IPNet{proxy})
+	defer handlers.SetTrustedProxies(nil)
+
+	long := strings.Repeat("я", 127) + "ёж"
+	create := func(remoteAddr, name string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/task", strings.NewReader(`{"date":"20240101","title":"Автор"}`))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Actor", name)
+		rec := httptest.NewRecorder()
+		handlers.TaskHandler(database, false)(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var m map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+
+		rec = httptest.NewRecorder()
+		handlers.AuditHandler(database)(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/audit?task_id=%v", m["id"]), nil))
+		var resp struct {
+			Entries []auditEntry `json:"entries"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), rec.Body.String())
+		require.Len(t, resp.Entries, 1)
+		return resp.Entries[0].Actor
+	}
+
+	// Клиент не может назваться чужим именем.
+	assert.Equal(t, "192.0.2.7", create("192.0.2.7:5000", "admin"))
+	// Доверенный прокси может, имя обрезается по символам, а не по байтам.
+	assert.Equal(t, "alice", create("10.1.2.3:5000", "alice"))
+	name := create("10.1.2.3:5000", long)
+	assert.True(t, utf8.ValidString(name))
+	assert.Equal(t, strings.Repeat("я", 127)+"ё", name)
+	assert.Equal(t, "10.1.2.3", create("10.1.2.3:5000", ""))
+}
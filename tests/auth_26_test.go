@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"go_final_project/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// adminToken возвращает токен служебного API работающего сервера. Без него
+// тесты служебного API пропускаются.
+func adminToken(t *testing.T) string {
+	t.Helper()
+	token := os.Getenv("TODO_ADMIN_TOKEN")
+	if token == "" {
+		t.Skip("служебный API выключен: запустите сервер и тесты с TODO_ADMIN_TOKEN")
+	}
+	return token
+}
+
+func TestSecureCookie(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tasks", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tasks":[]}`))
+	})
+	server := httptest.NewTLSServer(handlers.SecureHeaders(mux, 0))
+	defer server.Close()
+	client := server.Client()
+
+	get := func(cookie *http.Cookie) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api/tasks", nil)
+		require.NoError(t, err)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return resp
+	}
+
+	resp := get(nil)
+	assert.Equal(t, "max-age=0; includeSubDomains", resp.Header.Get("Strict-Transport-Security"))
+	assert.Empty(t, resp.Cookies())
+
+	// Cookie, поставленная веб-интерфейсом, переустанавливается со всеми флагами.
+	token := &http.Cookie{Name: "token", Value: "token-" + t.Name()}
+	cookies := get(token).Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "token", cookies[0].Name)
+	assert.Equal(t, token.Value, cookies[0].Value)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+	assert.False(t, cookies[0].Expires.IsZero())
+
+	// Повторно срок не продлевается.
+	assert.Empty(t, get(token).Cookies())
+}
+
+func TestAdmin(t *testing.T) {
+	var called int
+	next := func(w http.ResponseWriter, r *http.Request) { called++ }
+	request := func(handler func(w http.ResponseWriter, r *http.Request), method, authorization string) int {
+		req := httptest.NewRequest(method, "/api/admin/backup", nil)
+		req.RemoteAddr = "127.0.0.1:40000"
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	// Без токена служебный API выключен даже для локальных адресов.
+	assert.Equal(t, http.StatusForbidden, request(handlers.Admin("", next), http.MethodGet, ""))
+	assert.Equal(t, http.StatusForbidden, request(handlers.Admin("", next), http.MethodGet, "Bearer "))
+
+	admin := handlers.Admin("секрет", next)
+	assert.Equal(t, http.StatusUnauthorized, request(admin, http.MethodGet, ""))
+	assert.Equal(t, http.StatusUnauthorized, request(admin, http.MethodGet, "Bearer неверный"))
+	assert.Equal(t, http.StatusUnauthorized, request(admin, http.MethodGet, "секрет"))
+	assert.Equal(t, http.StatusOK, request(admin, http.MethodGet, "Bearer секрет"))
+	assert.Equal(t, 1, called)
+
+	writes := handlers.AdminWrites("секрет", next)
+	assert.Equal(t, http.StatusOK, request(writes, http.MethodGet, ""))
+	assert.Equal(t, http.StatusUnauthorized, request(writes, http.MethodPost, ""))
+	assert.Equal(t, http.StatusUnauthorized, request(writes, http.MethodDelete, "Bearer неверный"))
+	assert.Equal(t, http.StatusOK, request(writes, http.MethodDelete, "Bearer секрет"))
+	assert.Equal(t, 3, called)
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go_final_project/backup"
+	"go_final_project/db"
+	"go_final_project/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSnapshot создаёт в dir базу с одной задачей и её резервную копию.
+func newSnapshot(t *testing.T, dir, title string) string {
+	t.Helper()
+	source := filepath.Join(dir, title+".db")
+	database, err := db.Open(source, db.Options{BusyTimeout: time.Second})
+	require.NoError(t, err)
+	defer database.Close()
+	require.NoError(t, db.Migrate(database))
+	_, err = database.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, '', '')`, "20240101", title)
+	require.NoError(t, err)
+
+	snapshot := filepath.Join(dir, title+"-snapshot.db")
+	require.NoError(t, db.Backup(database, snapshot))
+	return snapshot
+}
+
+func taskTitles(t *testing.T, path string) []string {
+	t.Helper()
+	database, err := db.Open(path, db.Options{BusyTimeout: time.Second})
+	require.NoError(t, err)
+	defer database.Close()
+	tasks, err := db.AllTasks(database)
+	require.NoError(t, err)
+	var titles []string
+	for _, task := range tasks {
+		titles = append(titles, task.Title)
+	}
+	return titles
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "scheduler.db")
+	newSnapshot(t, dir, "before")
+	require.NoError(t, os.Rename(filepath.Join(dir, "before.db"), target))
+	snapshot := newSnapshot(t, dir, "after")
+
+	require.NoError(t, db.ValidateSnapshot(snapshot))
+	require.NoError(t, db.Restore(snapshot, target))
+	assert.Equal(t, []string{"after"}, taskTitles(t, target))
+
+	// Повреждённый файл не проходит проверку, база остаётся прежней.
+	corrupt := filepath.Join(dir, "corrupt.db")
+	require.NoError(t, os.WriteFile(corrupt, []byte("это не база SQLite"), 0o644))
+	assert.Error(t, db.ValidateSnapshot(corrupt))
+	assert.Error(t, db.Restore(corrupt, target))
+	assert.Equal(t, []string{"after"}, taskTitles(t, target))
+
+	// Копия из более новой версии сервера.
+	newer := newSnapshot(t, dir, "newer")
+	conn, err := db.Open(newer, db.Options{})
+	require.NoError(t, err)
+	_, err = conn.Exec(`PRAGMA user_version = 1000`)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	assert.ErrorContains(t, db.ValidateSnapshot(newer), "версия схемы")
+	assert.Error(t, db.Restore(newer, target))
+	assert.Equal(t, []string{"after"}, taskTitles(t, target))
+
+	// Открытую базу заменить нельзя.
+	live, err := db.Open(target, db.Options{})
+	require.NoError(t, err)
+	assert.ErrorIs(t, db.Restore(snapshot, target), db.ErrLocked)
+	require.NoError(t, live.Close())
+	assert.NoError(t, db.Restore(snapshot, target))
+}
+
+func TestBackupAdminOnly(t *testing.T) {
+	manager := &backup.Manager{Dir: t.TempDir()}
+	for _, v := range []struct {
+		token         string
+		authorization string
+		code          int
+	}{
+		{"", "", http.StatusForbidden},
+		{"секрет", "", http.StatusUnauthorized},
+		{"секрет", "Bearer секрет", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/backup", nil)
+		req.RemoteAddr = "127.0.0.1:40000"
+		if v.authorization != "" {
+			req.Header.Set("Authorization", v.authorization)
+		}
+		rec := httptest.NewRecorder()
+		handlers.Admin(v.token, handlers.BackupHandler(manager))(rec, req)
+		assert.Equal(t, v.code, rec.Code, v)
+	}
+}
+
+func TestBackupDoesNotBlockRequests(t *testing.T) {
+	dir := t.TempDir()
+	database, err := db.Open(filepath.Join(dir, "scheduler.db"), db.Options{BusyTimeout: time.Second})
+	require.NoError(t, err)
+	defer database.Close()
+	require.NoError(t, db.Migrate(database))
+	manager := &backup.Manager{DB: database, Dir: filepath.Join(dir, "backups")}
+
+	// Транзакция занимает единственное соединение пула, как долгий запрос к API.
+	tx, err := database.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, '', '')`, "20240101", "В транзакции")
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := manager.Snapshot()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("снимок ждёт соединения из пула")
+	}
+	require.NoError(t, tx.Commit())
+
+	// API отдаёт имена снимков, а не пути на сервере.
+	rec := httptest.NewRecorder()
+	handlers.BackupHandler(manager)(rec, httptest.NewRequest(http.MethodGet, "/api/admin/backup", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), dir)
+	var resp struct {
+		Backups []map[string]any `json:"backups"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Backups, 1)
+	assert.True(t, strings.HasPrefix(resp.Backups[0]["name"].(string), "scheduler-"), resp.Backups[0])
+	assert.NotContains(t, resp.Backups[0], "path")
+}
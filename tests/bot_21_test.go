@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go_final_project/bot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTelegram - локальный сервер с методами getUpdates и sendMessage Bot API.
+type fakeTelegram struct {
+	mu      sync.Mutex
+	updates []map[string]any
+	sent    []string
+	nextID  int64
+}
+
+func (f *fakeTelegram) say(chatID int64, text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	f.updates = append(f.updates, map[string]any{
+		"update_id": f.nextID,
+		"message": map[string]any{
+			"text": text,
+			"chat": map[string]any{"id": chatID},
+			"from": map[string]any{"id": 7, "username": "tester"},
+		},
+	})
+}
+
+func (f *fakeTelegram) replies() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.sent...)
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Offset int64  `json:"offset"`
+		Text   string `json:"text"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	var result any = true
+	switch r.URL.Path {
+	case "/botTOKEN/getUpdates":
+		// Долгий опрос: ждём новых сообщений, но недолго.
+		deadline := time.Now().Add(200 * time.Millisecond)
+		for {
+			f.mu.Lock()
+			var pending []map[string]any
+			for _, u := range f.updates {
+				if u["update_id"].(int64) >= req.Offset {
+					pending = append(pending, u)
+				}
+			}
+			f.mu.Unlock()
+			if len(pending) > 0 || time.Now().After(deadline) {
+				result = pending
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	case "/botTOKEN/sendMessage":
+		f.mu.Lock()
+		f.sent = append(f.sent, req.Text)
+		f.mu.Unlock()
+	default:
+		json.NewEncoder(w).Encode(map[string]any{"ok": false, "description": "Unauthorized"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func TestBot(t *testing.T) {
+	fake := &fakeTelegram{}
+	telegram := httptest.NewServer(fake)
+	defer telegram.Close()
+
+	api, err := url.Parse(getURL(""))
+	require.NoError(t, err)
+	b := &bot.Bot{
+		Transport:    &bot.Telegram{BaseURL: telegram.URL, Token: "TOKEN"},
+		API:          httputil.NewSingleHostReverseProxy(api),
+		AllowedChats: []int64{100},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		b.Run(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	// ask отправляет сообщение и ждёт ответ бота.
+	ask := func(text string) string {
+		t.Helper()
+		before := len(fake.replies())
+		fake.say(100, text)
+		require.Eventually(t, func() bool { return len(fake.replies()) > before }, 5*time.Second, 10*time.Millisecond, text)
+		return fake.replies()[before]
+	}
+
+	today := time.Now().Format(`20060102`)
+	reply := ask("/add Поплавать в бассейне " + today + " d 7")
+	require.Contains(t, reply, "Добавлена задача", reply)
+	id := regexp.MustCompile(`задача (\d+)`).FindStringSubmatch(reply)[1]
+	defer postJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	task, err := postJSON("api/task?id="+id, nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.Equal(t, "Поплавать в бассейне", task["title"])
+	assert.Equal(t, today, task["date"])
+	assert.Equal(t, "d 7", task["repeat"])
+
+	// Правило без даты распознаётся разбором, а не по первой букве.
+	for _, v := range []struct{ text, title, repeat string }{
+		{"/add Сдать отчёт mw 1:1", "Сдать отчёт", "mw 1:1"},
+		{"/add Оплатить аренду m 1 >", "Оплатить аренду", "m 1 >"},
+		{"/add Проверить почту bd 1", "Проверить почту", "bd 1"},
+		{"/add Купить 2 кг d", "Купить 2 кг d", ""},
+	} {
+		reply := ask(v.text)
+		require.Contains(t, reply, "Добавлена задача", reply)
+		ruleID := regexp.MustCompile(`задача (\d+)`).FindStringSubmatch(reply)[1]
+		task, err := postJSON("api/task?id="+ruleID, nil, http.MethodGet)
+		postJSON("api/task?id="+ruleID, nil, http.MethodDelete)
+		require.NoError(t, err)
+		assert.Equal(t, v.title, task["title"], v.text)
+		assert.Equal(t, v.repeat, task["repeat"], v.text)
+	}
+
+	reply = ask("/today")
+	assert.Contains(t, reply, id+". Поплавать в бассейне (d 7)")
+
+	reply = ask("/done@todo_bot " + id)
+	assert.Equal(t, "Задача "+id+" выполнена", reply)
+	task, err = postJSON("api/task?id="+id, nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.Equal(t, time.Now().AddDate(0, 0, 7).Format(`20060102`), task["date"])
+
+	for _, text := range []string{"/add " + "Купить хлеб 20240230", "/done abc", "/done 999999999", "/archive 1"} {
+		reply = ask(text)
+		assert.False(t, strings.HasPrefix(reply, "Добавлена") || strings.HasSuffix(reply, "выполнена"), text+": "+reply)
+	}
+
+	// Сообщения из чужих чатов бот не выполняет.
+	before := len(fake.replies())
+	fake.say(200, "/add Чужая задача")
+	ask("/help")
+	replies := fake.replies()[before:]
+	require.Len(t, replies, 1)
+	assert.Contains(t, replies[0], "/add")
+}
+
+func TestBotNoAllowedChats(t *testing.T) {
+	fake := &fakeTelegram{}
+	telegram := httptest.NewServer(fake)
+	defer telegram.Close()
+
+	b := &bot.Bot{
+		Transport: &bot.Telegram{BaseURL: telegram.URL, Token: "TOKEN"},
+		API:       http.NotFoundHandler(),
+	}
+	// Без списка разрешённых чатов бот не отвечает ни в одном.
+	b.Handle(context.Background(), bot.Message{ChatID: 100, From: "tester", Text: "/help"})
+	assert.Empty(t, fake.replies())
+
+	b.AllowedChats = []int64{100}
+	b.Handle(context.Background(), bot.Message{ChatID: 100, From: "tester", Text: "/help"})
+	assert.Len(t, fake.replies(), 1)
+}
@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPI отвечает на запросы todo как сервер и запоминает, что пришло.
+type fakeAPI struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []map[string]any
+	task     map[string]any
+}
+
+func (f *fakeAPI) last() (*http.Request, map[string]any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[len(f.requests)-1], f.bodies[len(f.bodies)-1]
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]any
+	if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+		json.Unmarshal(data, &body)
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	f.bodies = append(f.bodies, body)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if cookie, err := r.Cookie("token"); r.URL.Path != "/api/signin" && (err != nil || cookie.Value != "secret-token") {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Требуется аутентификация"})
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/api/signin":
+		if body["password"] != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Неверный пароль"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": "secret-token"})
+	case r.URL.Path == "/api/tasks":
+		json.NewEncoder(w).Encode(map[string]any{"tasks": []any{f.task}})
+	case r.URL.Path == "/api/task" && r.Method == http.MethodGet:
+		if r.URL.Query().Get("id") != "1" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Задача не найдена"})
+			return
+		}
+		json.NewEncoder(w).Encode(f.task)
+	case r.URL.Path == "/api/task" && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		saved := map[string]any{"id": 1, "version": "2"}
+		for k, v := range body {
+			if k != "id" && k != "version" {
+				saved[k] = v
+			}
+		}
+		json.NewEncoder(w).Encode(saved)
+	case r.URL.Path == "/api/nextdate":
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "20240202")
+	default:
+		w.Write([]byte(`{}`))
+	}
+}
+
+func buildTodo(t *testing.T) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "todo")
+	out, err := exec.Command("go", "build", "-o", bin, "../cmd/todo").CombinedOutput()
+	require.NoError(t, err, string(out))
+	return bin
+}
+
+func TestCLI(t *testing.T) {
+	api := &fakeAPI{task: map[string]any{
+		"id": "1", "date": "20240101", "title": "Купить хлеб", "comment": "",
+		"repeat": "d 1", "priority": "high", "tags": []string{"дом"}, "version": "1",
+	}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	bin := buildTodo(t)
+	home := t.TempDir()
+
+	run := func(stdin string, args ...string) (string, string, error) {
+		cmd := exec.Command(bin, args...)
+		cmd.Env = append(os.Environ(),
+			"TODO_URL="+srv.URL,
+			"TODO_TOKEN=",
+			"HOME="+home,
+			"XDG_CONFIG_HOME="+filepath.Join(home, ".config"),
+		)
+		cmd.Stdin = strings.NewReader(stdin)
+		var stdout, stderr strings.Builder
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
+	}
+
+	// Без токена сервер отказывает, текст ошибки берётся из ответа.
+	_, stderr, err := run("", "ls")
+	require.Error(t, err)
+	assert.Contains(t, stderr, "Требуется аутентификация")
+
+	_, stderr, err = run("wrong\n", "login")
+	require.Error(t, err)
+	assert.Contains(t, stderr, "Неверный пароль")
+
+	_, stderr, err = run("pass\n", "login")
+	require.NoError(t, err, stderr)
+	assert.Contains(t, stderr, "Токен сохранён")
+
+	// Дальше токен берётся из сохранённого файла.
+	out, stderr, err := run("", "ls", "-search", "хлеб", "-tag", "дом,работа", "-sort", "priority", "-order", "desc")
+	require.NoError(t, err, stderr)
+	assert.Contains(t, out, "Купить хлеб")
+	assert.Contains(t, out, "high")
+	r, _ := api.last()
+	assert.Equal(t, "хлеб", r.URL.Query().Get("search"))
+	assert.Equal(t, []string{"дом", "работа"}, r.URL.Query()["tag"])
+	assert.Equal(t, "priority", r.URL.Query().Get("sort"))
+	assert.Equal(t, "desc", r.URL.Query().Get("order"))
+	assert.NotEmpty(t, r.Header.Get("X-Actor"))
+
+	out, stderr, err = run("", "-json", "show", "1")
+	require.NoError(t, err, stderr)
+	var shown map[string]any
+	require.NoError(t, json.Unmarshal([]byte(out), &shown))
+	assert.Equal(t, "Купить хлеб", shown["title"])
+
+	_, stderr, err = run("", "show", "2")
+	require.Error(t, err)
+	assert.Contains(t, stderr, "Задача не найдена")
+
+	// Флаги можно ставить после заголовка.
+	out, stderr, err = run("", "-json", "add", "Позвонить", "маме", "-date", "20240105", "-tags", "дом, семья", "-after", "1")
+	require.NoError(t, err, stderr)
+	r, body := api.last()
+	assert.Equal(t, http.MethodPost, r.Method)
+	assert.Empty(t, r.Header.Get("If-Match"))
+	assert.Equal(t, "Позвонить маме", body["title"])
+	assert.Equal(t, "20240105", body["date"])
+	assert.Equal(t, []any{"дом", "семья"}, body["tags"])
+	assert.Equal(t, []any{"1"}, body["blocked_by"])
+	var added map[string]any
+	require.NoError(t, json.Unmarshal([]byte(out), &added))
+	assert.Equal(t, "1", added["id"])
+
+	// edit меняет только указанные поля и передаёт версию в If-Match.
+	_, stderr, err = run("", "edit", "1", "-title", "Купить батон", "-tags", "")
+	require.NoError(t, err, stderr)
+	r, body = api.last()
+	assert.Equal(t, http.MethodPut, r.Method)
+	assert.Equal(t, `"1"`, r.Header.Get("If-Match"))
+	assert.Equal(t, "Купить батон", body["title"])
+	assert.Equal(t, "d 1", body["repeat"])
+	assert.Equal(t, []any{}, body["tags"])
+	assert.Nil(t, body["blocked_by"])
+
+	out, stderr, err = run("", "done", "1", "-force")
+	require.NoError(t, err, stderr)
+	assert.Equal(t, "ok\n", out)
+	r, _ = api.last()
+	assert.Equal(t, "/api/task/done", r.URL.Path)
+	assert.Equal(t, "true", r.URL.Query().Get("force"))
+
+	out, stderr, err = run("", "-json", "rm", "1")
+	require.NoError(t, err, stderr)
+	assert.JSONEq(t, `{"id":"1","status":"ok"}`, out)
+	r, _ = api.last()
+	assert.Equal(t, http.MethodDelete, r.Method)
+
+	out, stderr, err = run("", "next", "-now", "20240101", "-date", "20240101", "-repeat", "m 2")
+	require.NoError(t, err, stderr)
+	assert.Equal(t, "20240202\n", out)
+	r, _ = api.last()
+	assert.Equal(t, "m 2", r.URL.Query().Get("repeat"))
+
+	_, stderr, err = run("", "show")
+	require.Error(t, err)
+	assert.Contains(t, stderr, "нужно указать один идентификатор задачи")
+
+	_, stderr, err = run("", "fly")
+	require.Error(t, err)
+	assert.Contains(t, stderr, "неизвестная команда")
+}
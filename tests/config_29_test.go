@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go_final_project/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearEnv сбрасывает переменные TODO_*, чтобы окружение запуска не влияло на тест.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); strings.HasPrefix(name, "TODO_") {
+			t.Setenv(name, "")
+		}
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	yamlFile := `
+port: 8001
+db_file: yaml.db
+write_timeout: 1m
+backup_keep: 3
+require_if_match: true
+`
+	tbl := []struct {
+		name  string
+		yaml  string
+		env   map[string]string
+		args  []string
+		check func(t *testing.T, cfg config.Config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg config.Config) {
+				assert.Equal(t, config.Default(), cfg)
+			},
+		},
+		{
+			name: "yaml",
+			yaml: yamlFile,
+			check: func(t *testing.T, cfg config.Config) {
+				assert.Equal(t, 8001, cfg.Port)
+				assert.Equal(t, "yaml.db", cfg.DBFile)
+				assert.Equal(t, time.Minute, cfg.WriteTimeout)
+				assert.Equal(t, 3, cfg.BackupKeep)
+				assert.True(t, cfg.RequireIfMatch)
+				// Чего нет в файле, остаётся по умолчанию.
+				assert.Equal(t, config.Default().ReadTimeout, cfg.ReadTimeout)
+			},
+		},
+		{
+			name: "env over yaml",
+			yaml: yamlFile,
+			env: map[string]string{
+				"TODO_PORT":             "8002",
+				"TODO_WRITE_TIMEOUT":    "2m",
+				"TODO_REQUIRE_IF_MATCH": "false",
+			},
+			check: func(t *testing.T, cfg config.Config) {
+				assert.Equal(t, 8002, cfg.Port)
+				assert.Equal(t, 2*time.Minute, cfg.WriteTimeout)
+				assert.False(t, cfg.RequireIfMatch)
+				assert.Equal(t, "yaml.db", cfg.DBFile)
+			},
+		},
+		{
+			name: "flags over env",
+			yaml: yamlFile,
+			env: map[string]string{
+				"TODO_PORT":   "8002",
+				"TODO_DBFILE": "env.db",
+			},
+			args: []string{"-port", "8003", "-backup-keep", "0"},
+			check: func(t *testing.T, cfg config.Config) {
+				assert.Equal(t, 8003, cfg.Port)
+				assert.Equal(t, "env.db", cfg.DBFile)
+				// Явно заданный флаг побеждает, даже если равен значению по умолчанию.
+				assert.Equal(t, 0, cfg.BackupKeep)
+				assert.Equal(t, time.Minute, cfg.WriteTimeout)
+			},
+		},
+		{
+			name: "config from env",
+			env: map[string]string{
+				"TODO_CONFIG": writeConfig(t, "port: 8004\n"),
+			},
+			check: func(t *testing.T, cfg config.Config) {
+				assert.Equal(t, 8004, cfg.Port)
+			},
+		},
+		{
+			name: "dsn over db file",
+			args: []string{"-dsn", "postgres://localhost/todo"},
+			check: func(t *testing.T, cfg config.Config) {
+				assert.Equal(t, "postgres://localhost/todo", cfg.Database())
+			},
+		},
+	}
+
+	for _, v := range tbl {
+		t.Run(v.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range v.env {
+				t.Setenv(name, value)
+			}
+			args := v.args
+			if v.yaml != "" {
+				args = append([]string{"-config", writeConfig(t, v.yaml)}, args...)
+			}
+			cfg, err := config.Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+			require.NoError(t, err)
+			v.check(t, cfg)
+		})
+	}
+}
+
+func TestConfigInvalid(t *testing.T) {
+	tbl := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		args []string
+		err  string
+	}{
+		{name: "port", args: []string{"-port", "70000"}, err: "неверный порт 70000"},
+		{name: "zero port", yaml: "port: 0\n", err: "неверный порт 0"},
+		{name: "no database", args: []string{"-db", ""}, err: "не указан файл базы данных"},
+		{name: "negative timeout", args: []string{"-read-timeout", "-1s"}, err: "таймауты не могут быть отрицательными"},
+		{name: "negative backup keep", env: map[string]string{"TODO_BACKUP_KEEP": "-1"}, err: "параметры резервного копирования"},
+		{name: "backup without dir", args: []string{"-backup-interval", "1h", "-backup-dir", ""}, err: "не указана директория для резервных копий"},
+		{name: "postgres backup", args: []string{"-dsn", "postgres://localhost/todo", "-backup-interval", "1h"}, err: "только для SQLite"},
+		{name: "postgresql backup", env: map[string]string{"TODO_DSN": "postgresql://localhost/todo", "TODO_BACKUP_INTERVAL": "1h"}, err: "только для SQLite"},
+		{name: "smtp without recipients", args: []string{"-smtp-addr", "localhost:25"}, err: "нужно указать отправителя и получателей"},
+		{name: "bot chats", env: map[string]string{"TODO_BOT_CHATS": "1,abc"}, err: "неверный идентификатор чата abc"},
+		{name: "tls key only", args: []string{"-tls-key", "key.pem"}, err: "и сертификат, и ключ"},
+		{name: "redirect without tls", args: []string{"-redirect-port", "8080"}, err: "перенаправление на HTTPS требует TLS-сертификата"},
+		{name: "env int", env: map[string]string{"TODO_PORT": "abc"}, err: "неверное значение TODO_PORT"},
+		{name: "env duration", env: map[string]string{"TODO_WRITE_TIMEOUT": "10"}, err: "неверное значение TODO_WRITE_TIMEOUT"},
+		{name: "env bool", env: map[string]string{"TODO_REQUIRE_IF_MATCH": "maybe"}, err: "неверное значение TODO_REQUIRE_IF_MATCH"},
+		{name: "yaml syntax", yaml: "port: [\n", err: "ошибка в файле конфигурации"},
+		{name: "yaml type", yaml: "port: abc\n", err: "ошибка в файле конфигурации"},
+		{name: "missing file", args: []string{"-config", "/nonexistent/config.yaml"}, err: "ошибка при чтении файла конфигурации"},
+		{name: "flag value", args: []string{"-port", "abc"}, err: "invalid value"},
+	}
+
+	for _, v := range tbl {
+		t.Run(v.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range v.env {
+				t.Setenv(name, value)
+			}
+			args := v.args
+			if v.yaml != "" {
+				args = append([]string{"-config", writeConfig(t, v.yaml)}, args...)
+			}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			_, err := config.Load(fs, args)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), v.err)
+		})
+	}
+
+	// Validate собирает все ошибки сразу.
+	cfg := config.Default()
+	cfg.Port = -1
+	cfg.WebDir = ""
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "неверный порт -1")
+	assert.Contains(t, err.Error(), "не указана директория фронтенда")
+
+	// Хранилище определяется так же, как при открытии базы: файл SQLite
+	// с именем на "postgres" остаётся SQLite.
+	cfg = config.Default()
+	cfg.DSN = "postgres-backup.db"
+	cfg.BackupInterval = time.Hour
+	assert.NoError(t, cfg.Validate())
+}
@@ -7,18 +7,22 @@ import (
 	"time"
 
 	"go_final_project/config"
+	appdb "go_final_project/db"
 
 	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	_ "modernc.org/sqlite"
 )
 
 type Task struct {
-	ID      int64  `db:"id"`
-	Date    string `db:"date"`
-	Title   string `db:"title"`
-	Comment string `db:"comment"`
-	Repeat  string `db:"repeat"`
+	ID       int64  `db:"id"`
+	Date     string `db:"date"`
+	Title    string `db:"title"`
+	Comment  string `db:"comment"`
+	Repeat   string `db:"repeat"`
+	Version  int64  `db:"version"`
+	Priority string `db:"priority"`
 }
 
 func count(db *sqlx.DB) (int, error) {
@@ -26,14 +30,25 @@ func count(db *sqlx.DB) (int, error) {
 	return count, db.Get(&count, `SELECT count(id) FROM scheduler`)
 }
 
+// dbSource возвращает базу, с которой работает сервер: TODO_DSN для PostgreSQL,
+// иначе файл SQLite из TODO_DBFILE или путь по умолчанию.
+func dbSource() string {
+	if dsn := os.Getenv("TODO_DSN"); appdb.DialectOf(dsn) == appdb.Postgres {
+		return dsn
+	}
+	if envFile := os.Getenv("TODO_DBFILE"); envFile != "" {
+		return envFile
+	}
+	return "../" + config.DBFile
+}
+
 func openDB(t *testing.T) *sqlx.DB {
-	dbfile := "../" + config.DBFile
-	envFile := os.Getenv("TODO_DBFILE")
-	if len(envFile) > 0 {
-		dbfile = envFile
+	source := dbSource()
+	dialect := appdb.DialectOf(source)
+	if dialect == appdb.SQLite {
+		fmt.Println(source)
 	}
-	fmt.Println(dbfile)
-	db, err := sqlx.Connect("sqlite", dbfile)
+	db, err := sqlx.Connect(dialect.String(), source)
 	assert.NoError(t, err)
 	return db
 }
@@ -47,20 +62,19 @@ func TestDB(t *testing.T) {
 
 	today := time.Now().Format(`20060102`)
 
-	res, err := db.Exec(`INSERT INTO scheduler (date, title, comment, repeat) 
-	VALUES (?, 'Todo', 'Комментарий', '')`, today)
+	var id int64
+	err = db.Get(&id, db.Rebind(`INSERT INTO scheduler (date, title, comment, repeat) 
+	VALUES (?, 'Todo', 'Комментарий', '') RETURNING id`), today)
 	assert.NoError(t, err)
 
-	id, err := res.LastInsertId()
-
 	var task Task
-	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	err = db.Get(&task, db.Rebind(`SELECT * FROM scheduler WHERE id=?`), id)
 	assert.NoError(t, err)
 	assert.Equal(t, id, task.ID)
 	assert.Equal(t, `Todo`, task.Title)
 	assert.Equal(t, `Комментарий`, task.Comment)
 
-	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	_, err = db.Exec(db.Rebind(`DELETE FROM scheduler WHERE id = ?`), id)
 	assert.NoError(t, err)
 
 	after, err := count(db)
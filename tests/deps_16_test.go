@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func blockedTasks(t *testing.T) map[string]bool {
+	t.Helper()
+	body, err := requestJSON("api/tasks", nil, http.MethodGet)
+	require.NoError(t, err)
+	var resp struct {
+		Tasks []struct {
+			ID      string `json:"id"`
+			Blocked bool   `json:"blocked"`
+		} `json:"tasks"`
+	}
+	require.NoError(t, json.Unmarshal(body, &resp), string(body))
+	result := map[string]bool{}
+	for _, task := range resp.Tasks {
+		result[task.ID] = task.Blocked
+	}
+	return result
+}
+
+func blockedBy(t *testing.T, id string) []string {
+	t.Helper()
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	require.NoError(t, err)
+	var task struct {
+		BlockedBy []string `json:"blocked_by"`
+	}
+	require.NoError(t, json.Unmarshal(body, &task), string(body))
+	return task.BlockedBy
+}
+
+func TestDependencies(t *testing.T) {
+	now := time.Now().Format(`20060102`)
+	add := func(title, repeat string, after ...any) string {
+		t.Helper()
+		m, err := postJSON("api/task", map[string]any{
+			"date":       now,
+			"title":      title,
+			"repeat":     repeat,
+			"blocked_by": after,
+		}, http.MethodPost)
+		require.NoError(t, err)
+		require.NotNil(t, m["id"], m["error"])
+		return fmt.Sprint(m["id"])
+	}
+
+	// Купить краску -> Покрасить забор -> Убрать инструменты.
+	buy := add("Купить краску", "")
+	paint := add("Покрасить забор", "", buy)
+	tidy := add("Убрать инструменты", "d 7", paint)
+	ids := []string{buy, paint, tidy}
+	defer func() {
+		for _, id := range ids {
+			postJSON("api/task?id="+id, nil, http.MethodDelete)
+		}
+	}()
+
+	assert.Equal(t, []string{buy}, blockedBy(t, paint))
+	assert.Empty(t, blockedBy(t, buy))
+
+	blocked := blockedTasks(t)
+	assert.False(t, blocked[buy])
+	assert.True(t, blocked[paint])
+	assert.True(t, blocked[tidy])
+
+	// Цикл: «Купить краску» не может ждать «Убрать инструменты».
+	m, err := postJSON("api/task", map[string]any{
+		"id":         buy,
+		"date":       now,
+		"title":      "Купить краску",
+		"blocked_by": []string{tidy},
+	}, http.MethodPut)
+	require.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+	assert.Empty(t, blockedBy(t, buy))
+
+	for _, deps := range [][]any{{paint}, {"999999999"}, {"abc"}} {
+		m, err = postJSON("api/task", map[string]any{
+			"id":         paint,
+			"date":       now,
+			"title":      "Покрасить забор",
+			"blocked_by": deps,
+		}, http.MethodPut)
+		require.NoError(t, err)
+		assert.NotEmpty(t, m["error"], deps)
+	}
+
+	m, err = postJSON("api/task/done?id="+paint, nil, http.MethodPost)
+	require.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+	assert.Equal(t, []any{buy}, m["blocked_by"])
+
+	// Выполненная задача перестаёт блокировать следующую.
+	m, err = postJSON("api/task/done?id="+buy, nil, http.MethodPost)
+	require.NoError(t, err)
+	assert.Empty(t, m)
+	assert.Empty(t, blockedBy(t, paint))
+	assert.False(t, blockedTasks(t)[paint])
+
+	m, err = postJSON("api/task/done?id="+tidy+"&force=true", nil, http.MethodPost)
+	require.NoError(t, err)
+	assert.Empty(t, m)
+	assert.Equal(t, []string{paint}, blockedBy(t, tidy))
+
+	// Правка без blocked_by зависимости не трогает, пустой список их снимает.
+	m, err = postJSON("api/task", map[string]any{
+		"id":     tidy,
+		"date":   now,
+		"title":  "Убрать инструменты",
+		"repeat": "d 7",
+	}, http.MethodPut)
+	require.NoError(t, err)
+	assert.Equal(t, []any{paint}, m["blocked_by"])
+
+	m, err = postJSON("api/task", map[string]any{
+		"id":         tidy,
+		"date":       now,
+		"title":      "Убрать инструменты",
+		"repeat":     "d 7",
+		"blocked_by": []string{},
+	}, http.MethodPut)
+	require.NoError(t, err)
+	assert.Nil(t, m["blocked_by"])
+	assert.Empty(t, blockedBy(t, tidy))
+}
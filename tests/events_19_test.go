@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// openEvents подключается к /api/events и возвращает канал разобранных событий.
+func openEvents(t *testing.T, lastEventID string) <-chan sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, getURL("api/events"), nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"))
+
+	ch := make(chan sseEvent, 100)
+	go func() {
+		defer resp.Body.Close()
+		defer close(ch)
+		var e sseEvent
+		lines := bufio.NewScanner(resp.Body)
+		for lines.Scan() {
+			line := lines.Text()
+			switch {
+			case line == "":
+				if e.event != "" {
+					ch <- e
+				}
+				e = sseEvent{}
+			case strings.HasPrefix(line, "id: "):
+				e.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				e.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				e.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return ch
+}
+
+// nextTaskEvent ждёт событие о задаче id, пропуская события других задач.
+func nextTaskEvent(t *testing.T, ch <-chan sseEvent, id string) sseEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-ch:
+			require.True(t, ok, "поток событий закрыт")
+			var payload struct {
+				Task struct {
+					ID string `json:"id"`
+				} `json:"task"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(e.data), &payload), e.data)
+			if payload.Task.ID == id {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("нет события о задаче %s", id)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	stream := openEvents(t, "")
+
+	now := time.Now().Format(`20060102`)
+	m, err := postJSON("api/task", map[string]any{"date": now, "title": "Вынести мусор"}, http.MethodPost)
+	require.NoError(t, err)
+	require.NotNil(t, m["id"], m["error"])
+	id := fmt.Sprint(m["id"])
+
+	created := nextTaskEvent(t, stream, id)
+	assert.Equal(t, "task.created", created.event)
+	assert.Contains(t, created.data, "Вынести мусор")
+
+	m, err = postJSON("api/task", map[string]any{"id": id, "date": now, "title": "Вынести мусор утром"}, http.MethodPut)
+	require.NoError(t, err)
+	require.Empty(t, m["error"])
+	updated := nextTaskEvent(t, stream, id)
+	assert.Equal(t, "task.updated", updated.event)
+	assert.Contains(t, updated.data, "Вынести мусор утром")
+
+	m, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	require.NoError(t, err)
+	require.Empty(t, m)
+	done := nextTaskEvent(t, stream, id)
+	assert.Equal(t, "task.done", done.event)
+
+	// Переподключение с Last-Event-ID досылает пропущенные события.
+	resumed := openEvents(t, created.id)
+	assert.Equal(t, "task.updated", nextTaskEvent(t, resumed, id).event)
+	assert.Equal(t, "task.done", nextTaskEvent(t, resumed, id).event)
+
+	// Слишком старый идентификатор - клиенту нужно перечитать список.
+	reset := openEvents(t, "1")
+	select {
+	case e := <-reset:
+		assert.Equal(t, "reset", e.event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("нет события reset")
+	}
+}
@@ -0,0 +1,28 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	body, err := getBody("healthz")
+	assert.NoError(t, err)
+	var health map[string]string
+	assert.NoError(t, json.Unmarshal(body, &health))
+	assert.Equal(t, "ok", health["status"])
+
+	body, err = getBody("readyz")
+	assert.NoError(t, err)
+	var ready struct {
+		Status     string            `json:"status"`
+		Components map[string]string `json:"components"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &ready))
+	assert.Equal(t, "ready", ready.Status)
+	for _, name := range []string{"database", "migrations", "web", "server"} {
+		assert.Equal(t, "ok", ready.Components[name], "компонент %s", name)
+	}
+}
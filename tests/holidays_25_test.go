@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go_final_project/calendar"
+	"go_final_project/db"
+	"go_final_project/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 1 января 2099 года - четверг, 31 января - суббота.
+func checkNextDates(t *testing.T, now string, tbl []nextDate) {
+	t.Helper()
+	for _, v := range tbl {
+		get, err := getBody(fmt.Sprintf("api/nextdate?now=%s&date=%s&repeat=%s",
+			now, url.QueryEscape(v.date), url.QueryEscape(v.repeat)))
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		if len(v.want) == 0 {
+			assert.NotRegexp(t, `^\d{8}$`, next, `{%q, %q}`, v.date, v.repeat)
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`, v.date, v.repeat, v.want)
+	}
+}
+
+func importCalendar(t *testing.T, body string) map[string]any {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, getURL("api/holidays/import?replace=true"), strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Authorization", "Bearer "+adminToken(t))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(data, &m), string(data))
+	return m
+}
+
+func TestBusinessDays(t *testing.T) {
+	adminToken(t)
+	_, err := requestJSON("api/holidays?year=2099", nil, http.MethodDelete)
+	require.NoError(t, err)
+	defer requestJSON("api/holidays?year=2099", nil, http.MethodDelete)
+
+	// Без календаря рабочие дни - понедельник-пятница.
+	checkNextDates(t, "20990101", []nextDate{
+		{"20990101", "bd 1", "20990102"},
+		{"20990102", "bd 1", "20990105"},
+		{"20990101", "bd 5", "20990108"},
+		{"20990101", "m 31 >", "20990202"},
+		{"20990101", "m 31 <", "20990130"},
+		{"20990130", "m 31 <", "20990331"},
+		{"20990101", "d 2 >", "20990105"},
+		{"20990101", "bd", ""},
+		{"20990101", "bd 0", ""},
+		{"20990101", "bd 401", ""},
+		{"20990101", "bd 1 2", ""},
+		{"20990101", "m 31 >>", ""},
+		{"20990101", ">", ""},
+	})
+
+	m := importCalendar(t, `# Тестовый календарь
+20990102 holiday Тестовый праздник
+20990105
+20990110 workday
+`)
+	require.Nil(t, m["error"], m["error"])
+	assert.EqualValues(t, 3, m["imported"])
+
+	checkNextDates(t, "20990101", []nextDate{
+		{"20990101", "bd 1", "20990106"},
+		{"20990106", "bd 3", "20990109"},
+		{"20990106", "bd 4", "20990110"},
+		{"20990101", "d 1 >", "20990106"},
+		{"20990107", "d 3 <", "20990110"},
+	})
+
+	// Формат xmlcalendar.ru: t=1 - выходной, t=2 - сокращённый, t=3 - рабочий.
+	m = importCalendar(t, `<?xml version="1.0" encoding="UTF-8"?>
+<calendar year="2099" lang="ru" country="ru">
+  <holidays><holiday id="1" title="Новогодние каникулы"/></holidays>
+  <days>
+    <day d="01.02" t="1" h="1"/>
+    <day d="01.05" t="1"/>
+    <day d="01.07" t="2"/>
+    <day d="01.11" t="3" f="01.05"/>
+  </days>
+</calendar>`)
+	require.Nil(t, m["error"], m["error"])
+	assert.EqualValues(t, 3, m["imported"])
+
+	body, err := getBody("api/holidays?year=2099")
+	require.NoError(t, err)
+	var list struct {
+		Holidays []struct {
+			Date  string `json:"date"`
+			Kind  string `json:"kind"`
+			Title string `json:"title"`
+		} `json:"holidays"`
+	}
+	require.NoError(t, json.Unmarshal(body, &list), string(body))
+	require.Len(t, list.Holidays, 3)
+	assert.Equal(t, "20990102", list.Holidays[0].Date)
+	assert.Equal(t, "Новогодние каникулы", list.Holidays[0].Title)
+	assert.Equal(t, "20990111", list.Holidays[2].Date)
+	assert.Equal(t, "workday", list.Holidays[2].Kind)
+	checkNextDates(t, "20990101", []nextDate{
+		{"20990106", "bd 4", "20990111"},
+	})
+
+	m, err = postJSON("api/holidays", map[string]any{"date": "20990108", "title": "Ещё праздник"}, http.MethodPost)
+	require.NoError(t, err)
+	assert.Equal(t, "holiday", m["kind"], m["error"])
+	checkNextDates(t, "20990101", []nextDate{
+		{"20990106", "bd 2", "20990109"},
+	})
+
+	m, err = postJSON("api/holidays?date=20990108", nil, http.MethodDelete)
+	require.NoError(t, err)
+	assert.Empty(t, m)
+	m, err = postJSON("api/holidays?date=20990108", nil, http.MethodDelete)
+	require.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+
+	for _, day := range []map[string]any{
+		{"date": "2099-01-08"},
+		{"date": "20990108", "kind": "vacation"},
+	} {
+		m, err = postJSON("api/holidays", day, http.MethodPost)
+		require.NoError(t, err)
+		assert.NotEmpty(t, m["error"], day)
+	}
+	m = importCalendar(t, "20990132 holiday")
+	assert.NotEmpty(t, m["error"])
+	m = importCalendar(t, `<calendar year="2099"><days><day d="01.02" t="1"`)
+	assert.NotEmpty(t, m["error"])
+}
+
+func TestHolidaysReload(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "calendar.db"), db.Options{BusyTimeout: time.Second})
+	require.NoError(t, err)
+	defer database.Close()
+	require.NoError(t, db.Migrate(database))
+
+	cal := &calendar.Calendar{}
+	require.NoError(t, cal.Load(database))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cal.Run(ctx, database, 20*time.Millisecond)
+
+	// Календарь изменён в обход API, как это делает команда holidays.
+	day := time.Date(2099, 3, 2, 0, 0, 0, 0, time.UTC)
+	require.True(t, cal.Working(day))
+	require.NoError(t, calendar.Import(database, []calendar.Day{{Date: "20990302", Kind: calendar.Holiday}}, false))
+	assert.Eventually(t, func() bool { return !cal.Working(day) }, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestHolidaysAdminWrites(t *testing.T) {
+	var called int
+	handler := handlers.AdminWrites("", func(w http.ResponseWriter, r *http.Request) { called++ })
+	for _, v := range []struct {
+		method string
+		code   int
+	}{
+		{http.MethodGet, http.StatusOK},
+		{http.MethodPost, http.StatusForbidden},
+		{http.MethodDelete, http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(v.method, "/api/holidays", nil)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		assert.Equal(t, v.code, rec.Code, v.method)
+	}
+	assert.Equal(t, 1, called)
+}
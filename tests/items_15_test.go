@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	appdb "go_final_project/db"
+	"go_final_project/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type checklistItem struct {
+	ID       string `json:"id"`
+	Position int    `json:"position"`
+	Title    string `json:"title"`
+	Done     bool   `json:"done"`
+}
+
+func taskWithItems(t *testing.T, id string) []checklistItem {
+	t.Helper()
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	require.NoError(t, err)
+	var task struct {
+		Items []checklistItem `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(body, &task), string(body))
+	return task.Items
+}
+
+func itemTitles(items []checklistItem) []string {
+	var titles []string
+	for i, item := range items {
+		if item.Position != i+1 {
+			return nil
+		}
+		titles = append(titles, item.Title)
+	}
+	return titles
+}
+
+func TestChecklist(t *testing.T) {
+	now := time.Now().Format(`20060102`)
+	m, err := postJSON("api/task", map[string]any{
+		"date":   now,
+		"title":  "Оплатить коммуналку",
+		"repeat": "d 30",
+	}, http.MethodPost)
+	require.NoError(t, err)
+	require.NotNil(t, m["id"])
+	id := fmt.Sprint(m["id"])
+	defer postJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	var itemIDs []string
+	for _, title := range []string{"Снять показания", "Оплатить"} {
+		m, err := postJSON("api/task/items?task_id="+id, map[string]any{"title": title}, http.MethodPost)
+		require.NoError(t, err)
+		require.Empty(t, m["error"])
+		itemIDs = append(itemIDs, fmt.Sprint(m["id"]))
+	}
+	m, err = postJSON("api/task/items?task_id="+id, map[string]any{"title": "Найти квитанцию", "position": 1}, http.MethodPost)
+	require.NoError(t, err)
+	require.Empty(t, m["error"])
+	itemIDs = append(itemIDs, fmt.Sprint(m["id"]))
+
+	assert.Equal(t, []string{"Найти квитанцию", "Снять показания", "Оплатить"}, itemTitles(taskWithItems(t, id)))
+
+	m, err = postJSON("api/task/items?id="+itemIDs[1], map[string]any{"position": 1}, http.MethodPut)
+	require.NoError(t, err)
+	assert.Empty(t, m["error"])
+	assert.Equal(t, []string{"Оплатить", "Найти квитанцию", "Снять показания"}, itemTitles(taskWithItems(t, id)))
+
+	m, err = postJSON("api/task/items?id="+itemIDs[0], map[string]any{"done": true}, http.MethodPut)
+	require.NoError(t, err)
+	assert.Equal(t, true, m["done"])
+
+	m, err = postJSON("api/task/items?id="+itemIDs[0], map[string]any{"position": 10}, http.MethodPut)
+	require.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+
+	m, err = postJSON("api/task/items?task_id="+id, map[string]any{"title": "  "}, http.MethodPost)
+	require.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+
+	m, err = postJSON("api/task/items?task_id=999999999", map[string]any{"title": "Пункт"}, http.MethodPost)
+	require.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+
+	m, err = postJSON("api/task/items?id="+itemIDs[2], nil, http.MethodDelete)
+	require.NoError(t, err)
+	assert.Empty(t, m)
+	items := taskWithItems(t, id)
+	assert.Equal(t, []string{"Оплатить", "Снять показания"}, itemTitles(items))
+	assert.True(t, items[1].Done)
+
+	// Изменения чек-листа попадают в журнал как правка задачи.
+	var updates []auditEntry
+	for _, e := range getAudit(t, "task_id="+id) {
+		if e.Action == "update" {
+			updates = append(updates, e)
+		}
+	}
+	require.Len(t, updates, 6)
+	last := updates[0].Diff["items"]
+	require.NotNil(t, last.Before)
+	require.NotNil(t, last.After)
+	assert.Equal(t, "[ ] Оплатить\n[ ] Найти квитанцию\n[x] Снять показания", *last.Before)
+	assert.Equal(t, "[ ] Оплатить\n[x] Снять показания", *last.After)
+
+	body, err := requestJSON("api/task/items?task_id="+id, nil, http.MethodGet)
+	require.NoError(t, err)
+	var list struct {
+		Items []checklistItem `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(body, &list))
+	assert.Len(t, list.Items, 2)
+
+	// Выполнение повторяющейся задачи снимает отметки с пунктов.
+	m, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	require.NoError(t, err)
+	assert.Empty(t, m)
+	for _, item := range taskWithItems(t, id) {
+		assert.False(t, item.Done, item.Title)
+	}
+}
+
+func TestChecklistIfMatch(t *testing.T) {
+	database, err := appdb.Open(filepath.Join(t.TempDir(), "items.db"), appdb.Options{BusyTimeout: time.Second})
+	require.NoError(t, err)
+	defer database.Close()
+	require.NoError(t, appdb.Migrate(database))
+	items := handlers.TaskItemsHandler(database, true)
+
+	do := func(method, target, ifMatch, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		items(rec, req)
+		return rec
+	}
+
+	rec := httptest.NewRecorder()
+	handlers.TaskHandler(database, true)(rec, httptest.NewRequest(http.MethodPost, "/api/task",
+		strings.NewReader(`{"date":"20240101","title":"Чек-лист"}`)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+	id := fmt.Sprint(m["id"])
+
+	// require_if_match распространяется и на чек-лист.
+	rec = do(http.MethodPost, "/api/task/items?task_id="+id, "", `{"title":"Пункт"}`)
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code, rec.Body.String())
+
+	rec = do(http.MethodPost, "/api/task/items?task_id="+id, `"1"`, `{"title":"Пункт"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	var item checklistItem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &item))
+
+	// С устаревшей версией пункт не меняется, в ответе - текущая задача.
+	for _, v := range []struct{ method, body string }{
+		{http.MethodPut, `{"done":true}`},
+		{http.MethodDelete, ``},
+	} {
+		rec = do(v.method, "/api/task/items?id="+item.ID, `"1"`, v.body)
+		require.Equal(t, http.StatusPreconditionFailed, rec.Code, v.method)
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+		var conflict struct {
+			Task struct {
+				Items []checklistItem `json:"items"`
+			} `json:"task"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &conflict))
+		require.Len(t, conflict.Task.Items, 1)
+		assert.False(t, conflict.Task.Items[0].Done)
+	}
+
+	rec = do(http.MethodPut, "/api/task/items?id="+item.ID, `"2"`, `{"done":true}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	rec = do(http.MethodDelete, "/api/task/items?id="+item.ID, `"3"`, ``)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"4"`, rec.Header().Get("ETag"))
+}
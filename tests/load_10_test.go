@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"go_final_project/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelWrites(t *testing.T) {
+	const workers = 8
+	const iterations = 15
+
+	now := time.Now().Format(`20060102`)
+	errs := make(chan string, workers*iterations*4)
+
+	check := func(op string, m map[string]any, err error) {
+		if err != nil {
+			errs <- fmt.Sprintf("%s: %v", op, err)
+			return
+		}
+		if e, ok := m["error"]; ok {
+			errs <- fmt.Sprintf("%s: %v", op, e)
+		}
+	}
+
+	// Второй писатель - отдельное подключение к той же базе, как у CLI
+	// или второго экземпляра сервера. Он пишет, пока идёт нагрузка через API.
+	writer, err := db.Open(dbSource(), db.Options{BusyTimeout: 5 * time.Second})
+	require.NoError(t, err)
+	defer writer.Close()
+	stop := make(chan struct{})
+	writerDone := make(chan int)
+	go func() {
+		writes := 0
+		defer func() { writerDone <- writes }()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			tx, err := writer.Begin()
+			if err != nil {
+				errs <- fmt.Sprintf("второй писатель: %v", err)
+				return
+			}
+			id, err := tx.Insert(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, '', '')`,
+				now, fmt.Sprintf("Второй писатель %d", i))
+			if err == nil {
+				_, err = tx.Exec(`UPDATE scheduler SET comment = ? WHERE id = ?`, "параллельная запись", id)
+			}
+			if err == nil {
+				_, err = tx.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+			}
+			if err == nil {
+				err = tx.Commit()
+			} else {
+				tx.Rollback()
+			}
+			if err != nil {
+				errs <- fmt.Sprintf("второй писатель: %v", err)
+				return
+			}
+			writes++
+			// Без паузы писатель сразу снова захватывает блокировку и не даёт
+			// серверу её получить: так не ведёт себя ни один настоящий клиент.
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				m, err := postJSON("api/task", map[string]any{
+					"date":   now,
+					"title":  fmt.Sprintf("Нагрузка %d-%d", w, i),
+					"repeat": "d 1",
+				}, http.MethodPost)
+				check("POST", m, err)
+				if err != nil || m["id"] == nil {
+					continue
+				}
+				id := fmt.Sprint(m["id"])
+
+				m, err = postJSON("api/task", map[string]any{
+					"id":      id,
+					"date":    now,
+					"title":   fmt.Sprintf("Нагрузка %d-%d (изм.)", w, i),
+					"comment": "параллельная запись",
+					"repeat":  "d 1",
+				}, http.MethodPut)
+				check("PUT", m, err)
+
+				m, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+				check("done", m, err)
+
+				m, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+				check("DELETE", m, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(stop)
+	writes := <-writerDone
+	close(errs)
+
+	for e := range errs {
+		t.Error(e)
+	}
+	require.Positive(t, writes, "второй писатель ничего не записал")
+}
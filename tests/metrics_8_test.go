@@ -0,0 +1,31 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	_, err := getBody("api/nextdate?now=20240126&date=20240126&repeat=ooops")
+	assert.NoError(t, err)
+	_, err = getBody("api/tasks")
+	assert.NoError(t, err)
+
+	body, err := getBody("metrics")
+	assert.NoError(t, err)
+	text := string(body)
+
+	for _, name := range []string{
+		`scheduler_http_requests_total{route="/api/tasks",method="GET",code="200"}`,
+		`scheduler_http_request_duration_seconds_bucket{route="/api/nextdate",method="GET",le="+Inf"}`,
+		`scheduler_db_query_duration_seconds_count{query="select_tasks"}`,
+		`scheduler_tasks{state="upcoming"}`,
+		`scheduler_tasks{state="overdue"}`,
+		`scheduler_tasks{state="recurring"}`,
+		`scheduler_nextdate_errors_total `,
+	} {
+		assert.True(t, strings.Contains(text, name), "в /metrics нет %s", name)
+	}
+}
@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateNthWeekday(t *testing.T) {
+	tbl := []nextDate{
+		{"20240101", "mw 2:2", "20240213"},
+		{"20240101", "mw -1:5", "20240223"},
+		{"20240126", "mw 2:2,-1:5", "20240213"},
+		{"20240101", "mw 4:2", "20240227"},
+		{"20240101", "mw 5:2", "20240130"},
+		{"20240101", "mw 5:4", "20240229"},
+		{"20240101", "mw -2:5", "20240216"},
+		{"20240101", "mw -1:7", "20240128"},
+		{"20240101", "mw 1:1 3,6,9,12", "20240304"},
+		{"20240101", "mw 1:7 /2", "20240303"},
+		{"20240301", "mw -1:1", "20240325"},
+		{"20240126", "mw", ""},
+		{"20240126", "mw 2", ""},
+		{"20240126", "mw 2:", ""},
+		{"20240126", "mw 0:2", ""},
+		{"20240126", "mw 6:2", ""},
+		{"20240126", "mw -6:1", ""},
+		{"20240126", "mw 2:0", ""},
+		{"20240126", "mw 2:8", ""},
+		{"20240126", "mw 2-2", ""},
+		{"20240126", "mw 2:2 13", ""},
+		{"20240126", "mw 2:2 /0", ""},
+		{"20240126", "mw 2:2 1 2", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`,
+			v.date, v.repeat, v.want)
+	}
+}
@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWhen(t *testing.T) {
+	// 26 января 2024 года - пятница.
+	tbl := []struct {
+		text   string
+		date   string
+		repeat string
+	}{
+		{"сегодня", "20240126", ""},
+		{"завтра", "20240127", ""},
+		{"через 3 дня", "20240129", ""},
+		{"через неделю", "20240202", ""},
+		{"in 2 months", "20240326", ""},
+		{"в пятницу", "20240202", ""},
+		{"1 марта", "20240301", ""},
+		{"5 января", "20250105", ""},
+		{"march 10 2025", "20250310", ""},
+		{"каждый понедельник", "20240129", "w 1"},
+		{"каждую среду и пятницу", "20240126", "w 3,5"},
+		{"по будням", "20240126", "w 1,2,3,4,5"},
+		{"каждый день", "20240126", "d 1"},
+		{"каждые 3 дня", "20240126", "d 3"},
+		{"every 2 weeks", "20240126", "d 14"},
+		{"every year", "20240126", "y"},
+		{"last day of month", "20240131", "m -1"},
+		{"в последний день месяца", "20240131", "m -1"},
+		{"каждое 15 число", "20240215", "m 15"},
+		{"every month on the 15th", "20240215", "m 15"},
+		{"1 марта каждый месяц", "20240301", "m 1"},
+		{"завтра, every week", "20240127", "d 7"},
+		{"every other friday", "20240126", "w 5 /2"},
+		{"каждые 2 недели по средам", "20240131", "w 3 /2"},
+		{"every 3 months on the 15th", "20240215", "m 15 /3"},
+		{"каждые 2 года", "20240126", "y 2"},
+		{"второй вторник месяца", "20240213", "mw 2:2"},
+		{"last friday of the month", "20240126", "mw -1:5"},
+		{"второй вторник и последнюю пятницу каждого месяца", "20240126", "mw 2:2,-1:5"},
+		{"каждый второй вторник", "20240130", "w 2 /2"},
+		{"every third day", "20240126", "d 3"},
+		{"каждый рабочий день", "20240126", "bd 1"},
+		{"every 5 business days", "20240126", "bd 5"},
+		{"последний вторник", "", ""},
+		{"", "", ""},
+		{"когда-нибудь", "", ""},
+		{"через", "", ""},
+		{"every 60 weeks", "", ""},
+		{"31 февраля", "", ""},
+		{"завтра послезавтра", "", ""},
+		{`завтра "в обед"`, "", ""},
+	}
+	for _, v := range tbl {
+		body, err := getBody("api/parse?now=20240126&text=" + url.QueryEscape(v.text))
+		require.NoError(t, err)
+		var m map[string]string
+		require.NoError(t, json.Unmarshal(body, &m), string(body))
+		if v.date == "" {
+			assert.NotEmpty(t, m["error"], "ожидается ошибка для %q", v.text)
+			continue
+		}
+		assert.Empty(t, m["error"], v.text)
+		assert.Equal(t, v.date, m["date"], v.text)
+		assert.Equal(t, v.repeat, m["repeat"], v.text)
+	}
+}
+
+func TestAddTaskWhen(t *testing.T) {
+	m, err := postJSON("api/task", map[string]any{
+		"title": "Вынести мусор",
+		"when":  "каждый понедельник",
+	}, http.MethodPost)
+	require.NoError(t, err)
+	require.NotNil(t, m["id"], m["error"])
+	id := fmt.Sprint(m["id"])
+	defer postJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	task, err := postJSON("api/task?id="+id, nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.Equal(t, "w 1", task["repeat"])
+	date, err := time.Parse(`20060102`, fmt.Sprint(task["date"]))
+	require.NoError(t, err)
+	assert.Equal(t, time.Monday, date.Weekday())
+	assert.GreaterOrEqual(t, fmt.Sprint(task["date"]), time.Now().Format(`20060102`))
+
+	// Явно указанные поля важнее when.
+	m, err = postJSON("api/task", map[string]any{
+		"title":  "Полить цветы",
+		"when":   "завтра, каждую неделю",
+		"repeat": "d 3",
+	}, http.MethodPost)
+	require.NoError(t, err)
+	require.NotNil(t, m["id"], m["error"])
+	id = fmt.Sprint(m["id"])
+	defer postJSON("api/task?id="+id, nil, http.MethodDelete)
+	task, err = postJSON("api/task?id="+id, nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.Equal(t, time.Now().AddDate(0, 0, 1).Format(`20060102`), task["date"])
+	assert.Equal(t, "d 3", task["repeat"])
+
+	m, err = postJSON("api/task", map[string]any{"title": "Что-то", "when": "никогда"}, http.MethodPost)
+	require.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+	// Текст с кавычками и обратной косой чертой не ломает JSON ошибки.
+	m, err = postJSON("api/task", map[string]any{"title": "Что-то", "when": `завтра "ой\`}, http.MethodPost)
+	require.NoError(t, err)
+	assert.Equal(t, `не удалось разобрать «"ой\»`, m["error"])
+}
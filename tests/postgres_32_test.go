@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"go_final_project/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebind(t *testing.T) {
+	pg := &db.DB{Dialect: db.Postgres}
+	tbl := []struct {
+		query string
+		want  string
+	}{
+		{`SELECT 1`, `SELECT 1`},
+		{`SELECT * FROM scheduler WHERE id = ?`, `SELECT * FROM scheduler WHERE id = $1`},
+		{`UPDATE scheduler SET title = ?, comment = ? WHERE id = ? AND version = ?`,
+			`UPDATE scheduler SET title = $1, comment = $2 WHERE id = $3 AND version = $4`},
+		// Знак вопроса внутри строки - не плейсхолдер.
+		{`SELECT id FROM scheduler WHERE title = '?' AND id = ?`, `SELECT id FROM scheduler WHERE title = '?' AND id = $1`},
+		{`SELECT 'it''s ?', ?`, `SELECT 'it''s ?', $1`},
+	}
+	for _, v := range tbl {
+		assert.Equal(t, v.want, pg.Rebind(v.query))
+		// Для SQLite запрос не меняется.
+		assert.Equal(t, v.query, (&db.DB{Dialect: db.SQLite}).Rebind(v.query))
+	}
+}
+
+// TestPostgres проверяет то, что есть только у PostgreSQL: версию схемы
+// в таблице schema_version и RETURNING id. Запускается, когда TODO_DSN
+// указывает на PostgreSQL, на пустой схеме, которую потом удаляет.
+func TestPostgres(t *testing.T) {
+	dsn := dbSource()
+	if db.DialectOf(dsn) != db.Postgres {
+		t.Skip("TODO_DSN не указывает на PostgreSQL")
+	}
+	admin, err := db.Open(dsn, db.Options{})
+	require.NoError(t, err)
+	defer admin.Close()
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err = admin.Exec(`CREATE SCHEMA ` + schema)
+	require.NoError(t, err)
+	defer admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	database, err := db.Open(u.String(), db.Options{})
+	require.NoError(t, err)
+	defer database.Close()
+
+	version, err := db.Version(database)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	require.Error(t, db.CheckSchema(database))
+
+	require.NoError(t, db.Migrate(database))
+	// Повторный запуск ничего не меняет.
+	require.NoError(t, db.Migrate(database))
+	version, err = db.Version(database)
+	require.NoError(t, err)
+	assert.Equal(t, db.LatestVersion(), version)
+	require.NoError(t, db.CheckSchema(database))
+	var rows int
+	require.NoError(t, database.QueryRow(`SELECT count(*) FROM schema_version`).Scan(&rows))
+	assert.Equal(t, 1, rows)
+
+	insert := `INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`
+	first, err := database.Insert(insert, "20240101", "Первая", "", "")
+	require.NoError(t, err)
+	second, err := database.Insert(insert, "20240102", "Вторая?", "?", "d 1")
+	require.NoError(t, err)
+	assert.Positive(t, first)
+	assert.Greater(t, second, first)
+
+	var title string
+	require.NoError(t, database.QueryRow(`SELECT title FROM scheduler WHERE comment = '?' AND id = ?`, second).Scan(&title))
+	assert.Equal(t, "Вторая?", title)
+
+	// В транзакции RETURNING id работает так же, откат не оставляет строку.
+	tx, err := database.Begin()
+	require.NoError(t, err)
+	third, err := tx.Insert(insert, "20240103", "Откат", "", "")
+	require.NoError(t, err)
+	assert.Greater(t, third, second)
+	require.NoError(t, tx.Rollback())
+
+	tasks, err := db.AllTasks(database)
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "Первая", tasks[0].Title)
+	assert.Equal(t, "normal", tasks[0].Priority)
+	assert.Equal(t, "d 1", tasks[1].Repeat)
+}
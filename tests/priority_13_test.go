@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listTitles(t *testing.T, query, prefix string) []string {
+	t.Helper()
+	body, err := requestJSON("api/tasks?"+query, nil, http.MethodGet)
+	require.NoError(t, err)
+	var resp struct {
+		Tasks []map[string]string `json:"tasks"`
+	}
+	require.NoError(t, json.Unmarshal(body, &resp), string(body))
+	var titles []string
+	for _, task := range resp.Tasks {
+		if strings.HasPrefix(task["title"], prefix) {
+			titles = append(titles, task["title"])
+		}
+	}
+	return titles
+}
+
+func TestPriority(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now().Format(`20060102`)
+	var ids []string
+	for _, v := range []struct{ title, priority string }{
+		{"Приоритет Б", "low"},
+		{"Приоритет В", "urgent"},
+		{"Приоритет А", ""},
+		{"Приоритет Г", "high"},
+	} {
+		m, err := postJSON("api/task", map[string]any{
+			"date":     now,
+			"title":    v.title,
+			"priority": v.priority,
+		}, http.MethodPost)
+		require.NoError(t, err)
+		require.NotNil(t, m["id"], m["error"])
+		if v.priority == "" {
+			assert.Equal(t, "normal", m["priority"])
+		}
+		ids = append(ids, fmt.Sprint(m["id"]))
+	}
+	defer func() {
+		for _, id := range ids {
+			postJSON("api/task?id="+id, nil, http.MethodDelete)
+		}
+	}()
+
+	m, err := postJSON("api/task", map[string]any{
+		"date":     now,
+		"title":    "Приоритет Д",
+		"priority": "срочно",
+	}, http.MethodPost)
+	require.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+
+	assert.Equal(t, []string{"Приоритет В", "Приоритет Г", "Приоритет А", "Приоритет Б"},
+		listTitles(t, "sort=priority", "Приоритет"))
+	assert.Equal(t, []string{"Приоритет Б", "Приоритет А", "Приоритет Г", "Приоритет В"},
+		listTitles(t, "sort=priority&order=asc", "Приоритет"))
+	assert.Equal(t, []string{"Приоритет Г", "Приоритет В", "Приоритет Б", "Приоритет А"},
+		listTitles(t, "sort=title&order=desc", "Приоритет"))
+	assert.Equal(t, []string{"Приоритет Б", "Приоритет В", "Приоритет А", "Приоритет Г"},
+		listTitles(t, "sort=created", "Приоритет"))
+
+	for _, query := range []string{"sort=importance", "sort=date&order=up"} {
+		body, err := requestJSON("api/tasks?"+query, nil, http.MethodGet)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "error", query)
+	}
+
+	// Правка без приоритета его не сбрасывает.
+	m, err = postJSON("api/task", map[string]any{
+		"id":    ids[1],
+		"date":  now,
+		"title": "Приоритет В (изм.)",
+	}, http.MethodPut)
+	require.NoError(t, err)
+	assert.Equal(t, "urgent", m["priority"])
+
+	m, err = postJSON("api/task", map[string]any{
+		"id":       ids[1],
+		"date":     now,
+		"title":    "Приоритет В (изм.)",
+		"priority": "low",
+	}, http.MethodPut)
+	require.NoError(t, err)
+	assert.Equal(t, "low", m["priority"])
+
+	var task Task
+	require.NoError(t, db.Get(&task, db.Rebind(`SELECT * FROM scheduler WHERE id=?`), ids[1]))
+	assert.Equal(t, "low", task.Priority)
+
+	m, err = postJSON("api/task", map[string]any{
+		"id":       ids[1],
+		"date":     now,
+		"title":    "Приоритет В (изм.)",
+		"priority": "важно",
+	}, http.MethodPut)
+	require.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+}
@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go_final_project/db"
+	"go_final_project/notify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openAppDB(t *testing.T) *db.DB {
+	t.Helper()
+	database, err := db.Open(dbSource(), db.Options{BusyTimeout: 5 * time.Second})
+	require.NoError(t, err)
+	return database
+}
+
+func TestReminders(t *testing.T) {
+	// Даты далеко в будущем, чтобы не задеть задачи из других тестов.
+	now := time.Date(2099, 1, 1, 9, 0, 0, 0, time.Local)
+	var ids []string
+	add := func(date, title string) string {
+		t.Helper()
+		m, err := postJSON("api/task", map[string]any{
+			"date":    date,
+			"title":   title,
+			"comment": "напоминание",
+		}, http.MethodPost)
+		require.NoError(t, err)
+		require.NotNil(t, m["id"], m["error"])
+		id := fmt.Sprint(m["id"])
+		ids = append(ids, id)
+		return id
+	}
+	defer func() {
+		for _, id := range ids {
+			postJSON("api/task?id="+id, nil, http.MethodDelete)
+		}
+	}()
+
+	today := add("20990101", "Полить цветы")
+	soon := add("20990102", "Оплатить интернет")
+	later := add("20990110", "Записаться к врачу")
+
+	var mu sync.Mutex
+	hooked := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Event string          `json:"event"`
+			Task  notify.Reminder `json:"task"`
+		}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil || body.Event != "reminder" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		hooked[body.Task.TaskID]++
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	database := openAppDB(t)
+	defer database.Close()
+
+	var buf bytes.Buffer
+	scheduler := &notify.Scheduler{
+		DB:       database,
+		Notifier: notify.Multi{&notify.Writer{W: &buf}, &notify.Webhook{URL: server.URL}},
+		Lead:     24 * time.Hour,
+		Now:      func() time.Time { return now },
+	}
+
+	_, err := scheduler.Check(context.Background())
+	require.NoError(t, err)
+
+	written := map[string]notify.Reminder{}
+	lines := bufio.NewScanner(&buf)
+	for lines.Scan() {
+		var r notify.Reminder
+		require.NoError(t, json.Unmarshal(lines.Bytes(), &r), lines.Text())
+		written[r.TaskID] = r
+	}
+	assert.Equal(t, "Полить цветы", written[today].Title)
+	assert.Equal(t, "20990102", written[soon].Date)
+	assert.NotContains(t, written, later)
+	assert.Equal(t, 1, hooked[today])
+	assert.Equal(t, 1, hooked[soon])
+
+	// Повторная проверка не отправляет те же напоминания ещё раз.
+	buf.Reset()
+	_, err = scheduler.Check(context.Background())
+	require.NoError(t, err)
+	assert.NotContains(t, buf.String(), `"id":"`+today+`"`)
+	assert.Equal(t, 1, hooked[today])
+
+	// Недоставленное напоминание повторяется при следующей проверке.
+	now = now.AddDate(0, 0, 9)
+	failing := httptest.NewServer(http.NotFoundHandler())
+	defer failing.Close()
+	scheduler.Notifier = &notify.Webhook{URL: failing.URL}
+	sent, err := scheduler.Check(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	scheduler.Notifier = &notify.Writer{W: &buf}
+	sent, err = scheduler.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Contains(t, buf.String(), `"id":"`+later+`"`)
+}
+
+type failingNotifier struct{ calls int }
+
+func (f *failingNotifier) Notify(ctx context.Context, r notify.Reminder) error {
+	f.calls++
+	return fmt.Errorf("канал недоступен")
+}
+
+func TestRemindersPartialDelivery(t *testing.T) {
+	now := time.Date(2099, 2, 1, 9, 0, 0, 0, time.Local)
+	m, err := postJSON("api/task", map[string]any{
+		"date":  "20990201",
+		"title": "Частичная доставка",
+	}, http.MethodPost)
+	require.NoError(t, err)
+	require.NotNil(t, m["id"], m["error"])
+	id := fmt.Sprint(m["id"])
+	defer postJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	database := openAppDB(t)
+	defer database.Close()
+
+	var buf bytes.Buffer
+	failing := &failingNotifier{}
+	scheduler := &notify.Scheduler{
+		DB:       database,
+		Notifier: notify.Multi{failing, &notify.Writer{W: &buf}},
+		Now:      func() time.Time { return now },
+	}
+
+	// Одного сработавшего способа достаточно: напоминание не повторяется.
+	sent, err := scheduler.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Contains(t, buf.String(), `"id":"`+id+`"`)
+	sent, err = scheduler.Check(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	assert.Equal(t, 1, failing.calls)
+
+	// Если не сработал ни один способ, ошибка возвращается.
+	err = notify.Multi{failing, failing}.Notify(context.Background(), notify.Reminder{TaskID: id})
+	assert.Error(t, err)
+}
+
+func TestSMTPTimeout(t *testing.T) {
+	// Сервер принимает соединение и молчит.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	smtp := &notify.SMTP{Addr: ln.Addr().String(), From: "todo@example.com", To: []string{"me@example.com"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Error(t, smtp.Notify(ctx, notify.Reminder{Title: "Тест"}))
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	smtp.Timeout = 200 * time.Millisecond
+	start = time.Now()
+	assert.Error(t, smtp.Notify(context.Background(), notify.Reminder{Title: "Тест"}))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
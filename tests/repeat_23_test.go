@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextDateIntervals(t *testing.T) {
+	tbl := []nextDate{
+		{"20240126", "y 2", "20260126"},
+		{"20220301", "y 2", "20240301"},
+		{"20200101", "y 3", "20260101"},
+		{"20240126", "y 0", ""},
+		{"20240126", "y 101", ""},
+		{"20240126", "y 2 3", ""},
+		{"20240126", "w 5 /2", "20240209"},
+		{"20240112", "w 5 /2", "20240209"},
+		{"20240101", "w 1,3 /3", "20240212"},
+		{"20240126", "w 5 /1", "20240202"},
+		{"20240126", "w 5 /0", ""},
+		{"20240126", "w 5 /53", ""},
+		{"20240126", "w 5 2", ""},
+		{"20240115", "m 15 /3", "20240415"},
+		{"20231130", "m -1 /2", "20240131"},
+		{"20240131", "m 31 /2", "20240331"},
+		{"20240126", "m 30 /12", "20240130"},
+		{"20240115", "m 15 /12", "20250115"},
+		{"20240126", "m 15 /25", ""},
+		{"20240126", "m 15 1,2 /3", ""},
+		{"20240126", "m /3", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`,
+			v.date, v.repeat, v.want)
+	}
+}
+
+func TestRepeatGrammar(t *testing.T) {
+	body, err := getBody("api/repeat")
+	require.NoError(t, err)
+	var grammar struct {
+		Rules []struct {
+			Rule     string   `json:"rule"`
+			Syntax   string   `json:"syntax"`
+			Examples []string `json:"examples"`
+		} `json:"rules"`
+	}
+	require.NoError(t, json.Unmarshal(body, &grammar), string(body))
+	var rules []string
+	for _, r := range grammar.Rules {
+		rules = append(rules, r.Rule)
+		assert.NotEmpty(t, r.Syntax)
+		// Все примеры из грамматики - правильные правила.
+		for _, example := range r.Examples {
+			var check struct {
+				Valid bool   `json:"valid"`
+				Error string `json:"error"`
+			}
+			body, err := getBody("api/repeat?date=20240101&now=20240126&repeat=" + url.QueryEscape(example))
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(body, &check), string(body))
+			assert.True(t, check.Valid, example+": "+check.Error)
+		}
+	}
+	assert.Subset(t, rules, []string{"d", "w", "m", "y"})
+
+	var check struct {
+		Valid bool     `json:"valid"`
+		Error string   `json:"error"`
+		Next  []string `json:"next"`
+	}
+	body, err = getBody("api/repeat?repeat=w+5+/2&date=20240126&now=20240126&count=3")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, &check), string(body))
+	assert.True(t, check.Valid)
+	assert.Equal(t, []string{"20240209", "20240223", "20240308"}, check.Next)
+
+	check.Next = nil
+	body, err = getBody("api/repeat?repeat=m+31+2")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, &check), string(body))
+	assert.False(t, check.Valid)
+	assert.NotEmpty(t, check.Error)
+	assert.Empty(t, check.Next)
+
+	body, err = getBody("api/repeat?repeat=d+1&count=100")
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"error"`)
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// waitLine ждёт в выводе сервера строку с текстом text.
+func waitLine(t *testing.T, lines <-chan string, text string) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			require.True(t, ok, "сервер завершился, не выведя %q", text)
+			if strings.Contains(line, text) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("не дождались %q", text)
+		}
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("нет SIGTERM")
+	}
+	bin := buildApp(t)
+	dir := t.TempDir()
+	port := freePort(t)
+	web, err := filepath.Abs("../web")
+	require.NoError(t, err)
+
+	cmd := exec.Command(bin, "serve",
+		"-port", strconv.Itoa(port),
+		"-db", filepath.Join(dir, "scheduler.db"),
+		"-web", web,
+		"-shutdown-timeout", "10s",
+		"-shutdown-drain-delay", "1s",
+		// Все фоновые задачи работают и должны остановиться вместе с сервером.
+		"-backup-dir", filepath.Join(dir, "backups"),
+		"-backup-interval", "1h",
+		"-reminder-interval", "1h",
+		"-reminder-file", filepath.Join(dir, "reminders.jsonl"),
+		"-webhook-interval", "1h",
+	)
+	cmd.Dir = dir
+	cmd.Env = append(cmd.Environ(), "TODO_CONFIG=", "TODO_DSN=", "TODO_PASSWORD=", "TODO_BOT_TOKEN=")
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	cmd.Stderr = cmd.Stdout
+	require.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+
+	lines := make(chan string, 100)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	waitLine(t, lines, "Сервер запущен")
+
+	// Запрос, тело которого ещё не дочитано, считается выполняющимся:
+	// сервер должен дождаться его, а не оборвать.
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		conn, err = net.Dial("tcp", addr)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	defer conn.Close()
+	body := `{"date":"20240101","title":"Во время остановки"}`
+	half := len(body) / 2
+	_, err = fmt.Fprintf(conn, "POST /api/task HTTP/1.1\r\nHost: %s\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", addr, len(body), body[:half])
+	require.NoError(t, err)
+	// Даём серверу прочитать заголовки.
+	time.Sleep(200 * time.Millisecond)
+
+	require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+	waitLine(t, lines, "Сервер останавливается")
+
+	// Пока идёт задержка, сервер принимает запросы, но /readyz отвечает 503,
+	// чтобы балансировщик убрал его из ротации.
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	ready, err := client.Get("http://" + addr + "/readyz")
+	require.NoError(t, err)
+	ready.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, ready.StatusCode)
+	health, err := client.Get("http://" + addr + "/healthz")
+	require.NoError(t, err)
+	health.Body.Close()
+	assert.Equal(t, http.StatusOK, health.StatusCode)
+
+	_, err = io.WriteString(conn, body[half:])
+	require.NoError(t, err)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(data))
+	assert.Contains(t, string(data), `"id"`)
+
+	waitLine(t, lines, "Сервер остановлен")
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("процесс не завершился после остановки сервера")
+	}
+
+	// Запрос, начатый до сигнала, сохранил задачу.
+	assert.Equal(t, []string{"Во время остановки"}, taskTitles(t, filepath.Join(dir, "scheduler.db")))
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type taggedTask struct {
+	ID    string   `json:"id"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+}
+
+func taggedTasks(t *testing.T, query string) map[string][]string {
+	t.Helper()
+	body, err := requestJSON("api/tasks?"+query, nil, http.MethodGet)
+	require.NoError(t, err)
+	var resp struct {
+		Tasks []taggedTask `json:"tasks"`
+	}
+	require.NoError(t, json.Unmarshal(body, &resp), string(body))
+	result := map[string][]string{}
+	for _, task := range resp.Tasks {
+		result[task.ID] = task.Tags
+	}
+	return result
+}
+
+func tagCounts(t *testing.T) map[string]int {
+	t.Helper()
+	body, err := requestJSON("api/tags", nil, http.MethodGet)
+	require.NoError(t, err)
+	var resp struct {
+		Tags []struct {
+			Name  string `json:"name"`
+			Count int    `json:"count"`
+		} `json:"tags"`
+	}
+	require.NoError(t, json.Unmarshal(body, &resp), string(body))
+	counts := map[string]int{}
+	for _, tag := range resp.Tags {
+		counts[tag.Name] = tag.Count
+	}
+	return counts
+}
+
+func TestTags(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now().Format(`20060102`)
+	var ids []string
+	for _, tags := range [][]string{
+		{"Work", "urgent-1", "work"},
+		{"work"},
+		{"home"},
+	} {
+		m, err := postJSON("api/task", map[string]any{
+			"date":  now,
+			"title": "Теги",
+			"tags":  tags,
+		}, http.MethodPost)
+		require.NoError(t, err)
+		require.NotNil(t, m["id"], m["error"])
+		ids = append(ids, fmt.Sprint(m["id"]))
+	}
+	// Задачи с тегами не должны попасть в тесты, которые ждут в списке только строки.
+	defer func() {
+		for _, id := range ids {
+			postJSON("api/task?id="+id, nil, http.MethodDelete)
+		}
+	}()
+
+	body, err := requestJSON("api/task?id="+ids[0], nil, http.MethodGet)
+	require.NoError(t, err)
+	var task taggedTask
+	require.NoError(t, json.Unmarshal(body, &task))
+	assert.Equal(t, []string{"urgent-1", "work"}, task.Tags)
+
+	list := taggedTasks(t, "tag=work")
+	assert.Contains(t, list, ids[0])
+	assert.Contains(t, list, ids[1])
+	assert.NotContains(t, list, ids[2])
+
+	list = taggedTasks(t, "tag=work&tag=urgent-1")
+	assert.Contains(t, list, ids[0])
+	assert.NotContains(t, list, ids[1])
+
+	list = taggedTasks(t, "tag=HOME")
+	assert.Equal(t, []string{"home"}, list[ids[2]])
+
+	counts := tagCounts(t)
+	assert.GreaterOrEqual(t, counts["work"], 2)
+	assert.GreaterOrEqual(t, counts["home"], 1)
+
+	// Без поля tags правка теги не трогает, пустой список их снимает.
+	m, err := postJSON("api/task", map[string]any{
+		"id":    ids[1],
+		"date":  now,
+		"title": "Теги (изм.)",
+	}, http.MethodPut)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"work"}, m["tags"])
+
+	m, err = postJSON("api/task", map[string]any{
+		"id":    ids[1],
+		"date":  now,
+		"title": "Теги (изм.)",
+		"tags":  []string{"home", "work"},
+	}, http.MethodPut)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"home", "work"}, m["tags"])
+
+	m, err = postJSON("api/task", map[string]any{
+		"id":    ids[1],
+		"date":  now,
+		"title": "Теги (изм.)",
+		"tags":  []string{},
+	}, http.MethodPut)
+	require.NoError(t, err)
+	assert.Nil(t, m["tags"])
+	assert.Empty(t, taggedTasks(t, "tag=work")[ids[1]])
+
+	var names []string
+	require.NoError(t, db.Select(&names, db.Rebind(`SELECT t.name FROM tags t JOIN task_tags tt ON tt.tag_id = t.id WHERE tt.task_id = ?`), ids[1]))
+	assert.Empty(t, names)
+
+	m, err = postJSON("api/task", map[string]any{
+		"date":  now,
+		"title": "Теги",
+		"tags":  []string{"два слова"},
+	}, http.MethodPost)
+	require.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+
+	// При удалении задачи её связи с тегами удаляются вместе с ней.
+	_, err = postJSON("api/task?id="+ids[0], nil, http.MethodDelete)
+	require.NoError(t, err)
+	var tagIDs []int64
+	require.NoError(t, db.Select(&tagIDs, db.Rebind(`SELECT tag_id FROM task_tags WHERE task_id = ?`), ids[0]))
+	assert.Empty(t, tagIDs)
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go_final_project/config"
+)
+
+func versionRequest(t *testing.T, method, apipath, ifMatch string, values map[string]any) (*http.Response, map[string]any) {
+	t.Helper()
+	var data []byte
+	if values != nil {
+		var err error
+		data, err = json.Marshal(values)
+		require.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	if len(config.Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: config.Token})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var m map[string]any
+	if len(body) > 0 {
+		require.NoError(t, json.Unmarshal(body, &m), string(body))
+	}
+	return resp, m
+}
+
+func TestTaskVersion(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now().Format(`20060102`)
+	m, err := postJSON("api/task", map[string]any{
+		"date":   now,
+		"title":  "Версия",
+		"repeat": "d 2",
+	}, http.MethodPost)
+	require.NoError(t, err)
+	require.NotNil(t, m["id"])
+	id := fmt.Sprint(m["id"])
+	assert.Equal(t, "1", m["version"])
+
+	resp, m := versionRequest(t, http.MethodGet, "api/task?id="+id, "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+	assert.Equal(t, "1", m["version"])
+
+	update := map[string]any{
+		"id":     id,
+		"date":   now,
+		"title":  "Версия (изм.)",
+		"repeat": "d 2",
+	}
+	resp, m = versionRequest(t, http.MethodPut, "api/task", `"1"`, update)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+	assert.Equal(t, "2", m["version"])
+
+	// Вторая правка по устаревшей версии не должна затереть первую.
+	update["title"] = "Устаревшая правка"
+	resp, m = versionRequest(t, http.MethodPut, "api/task", `"1"`, update)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+	assert.NotEmpty(t, m["error"])
+	current, ok := m["task"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "Версия (изм.)", current["title"])
+	assert.Equal(t, "2", current["version"])
+
+	var stored Task
+	require.NoError(t, db.Get(&stored, db.Rebind(`SELECT * FROM scheduler WHERE id=?`), id))
+	assert.Equal(t, "Версия (изм.)", stored.Title)
+	assert.Equal(t, int64(2), stored.Version)
+
+	resp, _ = versionRequest(t, http.MethodPut, "api/task", `"abc"`, update)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = versionRequest(t, http.MethodPost, "api/task/done?id="+id, `"1"`, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	// Слабый тег не совпадает даже с текущей версией.
+	resp, _ = versionRequest(t, http.MethodPost, "api/task/done?id="+id, `W/"2"`, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, m = versionRequest(t, http.MethodPost, "api/task/done?id="+id, `"2"`, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, m)
+
+	resp, _ = versionRequest(t, http.MethodDelete, "api/task?id="+id, `"2"`, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, m = versionRequest(t, http.MethodDelete, "api/task?id="+id, `"3"`, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, m)
+
+	resp, _ = versionRequest(t, http.MethodDelete, "api/task?id="+id, `"3"`, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go_final_project/handlers"
+	"go_final_project/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type hookRequest struct {
+	event     string
+	timestamp string
+	signature string
+	body      []byte
+}
+
+type hookDelivery struct {
+	ID           string `json:"id"`
+	Event        string `json:"event"`
+	Status       string `json:"status"`
+	Attempts     int    `json:"attempts"`
+	ResponseCode int    `json:"response_code"`
+	LastError    string `json:"last_error"`
+}
+
+func hookDeliveries(t *testing.T, webhookID string) []hookDelivery {
+	t.Helper()
+	body, err := requestJSON("api/webhooks/deliveries?webhook_id="+webhookID, nil, http.MethodGet)
+	require.NoError(t, err)
+	var resp struct {
+		Deliveries []hookDelivery `json:"deliveries"`
+	}
+	require.NoError(t, json.Unmarshal(body, &resp), string(body))
+	return resp.Deliveries
+}
+
+func TestWebhooks(t *testing.T) {
+	adminToken(t)
+	var mu sync.Mutex
+	var received []hookRequest
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, hookRequest{
+			event:     r.Header.Get("X-Webhook-Event"),
+			timestamp: r.Header.Get("X-Webhook-Timestamp"),
+			signature: r.Header.Get("X-Webhook-Signature"),
+			body:      body,
+		})
+		mu.Unlock()
+	}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	for _, bad := range []map[string]any{
+		{"url": "ftp://example.com"},
+		{"url": ok.URL, "events": []string{"task.archived"}},
+	} {
+		m, err := postJSON("api/webhooks", bad, http.MethodPost)
+		require.NoError(t, err)
+		assert.NotEmpty(t, m["error"], bad)
+	}
+
+	m, err := postJSON("api/webhooks", map[string]any{
+		"url":    ok.URL,
+		"secret": "s3cret",
+		"events": []string{webhook.TaskDone, webhook.TaskCreated, webhook.TaskCreated},
+	}, http.MethodPost)
+	require.NoError(t, err)
+	if e, _ := m["error"].(string); strings.Contains(e, "внутренней сети") {
+		t.Skip("подписчики теста слушают 127.0.0.1: запустите сервер с TODO_WEBHOOK_ALLOW_PRIVATE=true")
+	}
+	require.NotNil(t, m["id"], m["error"])
+	okID := fmt.Sprint(m["id"])
+	assert.Equal(t, []any{webhook.TaskCreated, webhook.TaskDone}, m["events"])
+
+	m, err = postJSON("api/webhooks", map[string]any{"url": failing.URL, "events": []string{webhook.TaskCreated}}, http.MethodPost)
+	require.NoError(t, err)
+	require.NotNil(t, m["id"], m["error"])
+	failingID := fmt.Sprint(m["id"])
+	assert.NotEmpty(t, m["secret"])
+
+	defer func() {
+		for _, id := range []string{okID, failingID} {
+			postJSON("api/webhooks?id="+id, nil, http.MethodDelete)
+		}
+	}()
+
+	body, err := requestJSON("api/webhooks", nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), ok.URL)
+	assert.NotContains(t, string(body), "s3cret")
+
+	now := time.Now().Format(`20060102`)
+	m, err = postJSON("api/task", map[string]any{"date": now, "title": "Позвонить маме"}, http.MethodPost)
+	require.NoError(t, err)
+	require.NotNil(t, m["id"], m["error"])
+	id := fmt.Sprint(m["id"])
+
+	// task.updated не входит в подписку и не отправляется.
+	m, err = postJSON("api/task", map[string]any{"id": id, "date": now, "title": "Позвонить маме вечером"}, http.MethodPut)
+	require.NoError(t, err)
+	require.Empty(t, m["error"])
+
+	m, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	require.NoError(t, err)
+	require.Empty(t, m)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) >= 2
+	}, 5*time.Second, 50*time.Millisecond)
+
+	mu.Lock()
+	got := append([]hookRequest(nil), received...)
+	mu.Unlock()
+	require.Len(t, got, 2)
+	for i, event := range []string{webhook.TaskCreated, webhook.TaskDone} {
+		assert.Equal(t, event, got[i].event)
+		assert.Equal(t, webhook.Sign("s3cret", got[i].timestamp, got[i].body), got[i].signature)
+		sent, err := strconv.ParseInt(got[i].timestamp, 10, 64)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), time.Unix(sent, 0), time.Minute)
+
+		var payload struct {
+			Event string `json:"event"`
+			Task  struct {
+				ID    string `json:"id"`
+				Title string `json:"title"`
+			} `json:"task"`
+		}
+		require.NoError(t, json.Unmarshal(got[i].body, &payload))
+		assert.Equal(t, event, payload.Event)
+		assert.Equal(t, id, payload.Task.ID)
+	}
+
+	require.Eventually(t, func() bool {
+		deliveries := hookDeliveries(t, okID)
+		return len(deliveries) == 2 && deliveries[0].Status == "delivered" && deliveries[1].Status == "delivered"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// Неудачная доставка остаётся в очереди и повторяется.
+	var failed hookDelivery
+	require.Eventually(t, func() bool {
+		deliveries := hookDeliveries(t, failingID)
+		if len(deliveries) != 1 || deliveries[0].Attempts == 0 {
+			return false
+		}
+		failed = deliveries[0]
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, "pending", failed.Status)
+	assert.Equal(t, http.StatusInternalServerError, failed.ResponseCode)
+	assert.NotEmpty(t, failed.LastError)
+
+	m, err = postJSON("api/webhooks/deliveries?id="+failed.ID, nil, http.MethodPost)
+	require.NoError(t, err)
+	assert.Empty(t, m)
+	require.Eventually(t, func() bool {
+		deliveries := hookDeliveries(t, failingID)
+		return len(deliveries) == 1 && deliveries[0].Attempts == 2
+	}, 5*time.Second, 50*time.Millisecond)
+
+	m, err = postJSON("api/webhooks?id="+failingID, nil, http.MethodDelete)
+	require.NoError(t, err)
+	assert.Empty(t, m)
+	m, err = postJSON("api/webhooks?id="+failingID, nil, http.MethodDelete)
+	require.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+	assert.Empty(t, hookDeliveries(t, failingID))
+}
+
+func TestWebhookPrivateAddress(t *testing.T) {
+	var hits int
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer local.Close()
+
+	database := openAppDB(t)
+	defer database.Close()
+	handler := handlers.WebhooksHandler(database, false)
+	for _, target := range []string{local.URL, "http://10.0.0.5/hook", "http://169.254.169.254/latest/meta-data", "http://[::1]:8080/"} {
+		body := strings.NewReader(`{"url":"` + target + `"}`)
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/api/webhooks", body))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+		assert.Contains(t, rec.Body.String(), "внутренней сети", target)
+	}
+
+	// Имя проверяется после разрешения, при установке соединения.
+	_, port, err := net.SplitHostPort(local.Listener.Addr().String())
+	require.NoError(t, err)
+	_, err = webhook.NewClient(false).Post("http://localhost:"+port+"/", "application/json", nil)
+	assert.ErrorIs(t, err, webhook.ErrForbiddenAddress)
+	assert.Zero(t, hits)
+
+	resp, err := webhook.NewClient(true).Post(local.URL, "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 1, hits)
+}
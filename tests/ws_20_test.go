@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type wsReply struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Status int             `json:"status"`
+	Error  string          `json:"error"`
+	Result json.RawMessage `json:"result"`
+	Event  *struct {
+		Type string `json:"type"`
+		Task struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		} `json:"task"`
+	} `json:"event"`
+}
+
+func dialWS(t *testing.T) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(getURL("api/ws"), "http://", "ws://", 1), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// wsCall отправляет сообщение и ждёт ответ с тем же id, пропуская события.
+func wsCall(t *testing.T, conn *websocket.Conn, msg map[string]any) wsReply {
+	t.Helper()
+	require.NoError(t, conn.WriteJSON(msg))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var reply wsReply
+		require.NoError(t, conn.ReadJSON(&reply))
+		if reply.ID == msg["id"] {
+			return reply
+		}
+	}
+}
+
+// wsEvent ждёт событие о задаче id, пропуская события других задач.
+func wsEvent(t *testing.T, conn *websocket.Conn, id string) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var reply wsReply
+		require.NoError(t, conn.ReadJSON(&reply))
+		if reply.Type == "event" && reply.Event.Task.ID == id {
+			return reply.Event.Type
+		}
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	editor, viewer := dialWS(t), dialWS(t)
+	for _, conn := range []*websocket.Conn{editor, viewer} {
+		reply := wsCall(t, conn, map[string]any{"id": "sub", "type": "subscribe"})
+		require.Equal(t, "ack", reply.Type, reply.Error)
+	}
+
+	now := time.Now().Format(`20060102`)
+
+	// Проверки те же, что у POST /api/task.
+	reply := wsCall(t, editor, map[string]any{"id": "1", "type": "create", "task": map[string]any{"date": now}})
+	assert.Equal(t, "error", reply.Type)
+	assert.Equal(t, 400, reply.Status)
+	assert.NotEmpty(t, reply.Error)
+
+	reply = wsCall(t, editor, map[string]any{"id": "2", "type": "create",
+		"task": map[string]any{"date": now, "title": "Забрать посылку"}})
+	require.Equal(t, "ack", reply.Type, reply.Error)
+	var created struct {
+		ID      json.Number `json:"id"`
+		Version string      `json:"version"`
+	}
+	require.NoError(t, json.Unmarshal(reply.Result, &created))
+	id := created.ID.String()
+	assert.Equal(t, "task.created", wsEvent(t, viewer, id))
+
+	reply = wsCall(t, editor, map[string]any{"id": "3", "type": "update", "version": "100",
+		"task": map[string]any{"id": id, "date": now, "title": "Забрать посылку на почте"}})
+	assert.Equal(t, "error", reply.Type)
+	assert.Equal(t, 412, reply.Status)
+
+	reply = wsCall(t, editor, map[string]any{"id": "4", "type": "update", "version": created.Version,
+		"task": map[string]any{"id": id, "date": now, "title": "Забрать посылку на почте"}})
+	require.Equal(t, "ack", reply.Type, reply.Error)
+	assert.Contains(t, string(reply.Result), "Забрать посылку на почте")
+	assert.Equal(t, "task.updated", wsEvent(t, viewer, id))
+
+	reply = wsCall(t, editor, map[string]any{"id": "5", "type": "done", "task_id": id})
+	require.Equal(t, "ack", reply.Type, reply.Error)
+	assert.Equal(t, "task.done", wsEvent(t, viewer, id))
+
+	reply = wsCall(t, editor, map[string]any{"id": "6", "type": "delete", "task_id": id})
+	assert.Equal(t, "error", reply.Type)
+	assert.Equal(t, 404, reply.Status)
+
+	reply = wsCall(t, editor, map[string]any{"id": "7", "type": "archive"})
+	assert.Equal(t, "error", reply.Type)
+
+	// Изменение, сделанное через HTTP, тоже приходит по WebSocket, а автор
+	// изменений по WebSocket не получает событий о собственных правках.
+	m, err := postJSON("api/task", map[string]any{"date": now, "title": "Полить кактус"}, "POST")
+	require.NoError(t, err)
+	require.NotNil(t, m["id"], m["error"])
+	other := fmt.Sprint(m["id"])
+	defer postJSON("api/task?id="+other, nil, "DELETE")
+	editor.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var event wsReply
+	require.NoError(t, editor.ReadJSON(&event))
+	require.Equal(t, "event", event.Type)
+	assert.Equal(t, other, event.Event.Task.ID)
+	assert.Equal(t, "task.created", event.Event.Type)
+}
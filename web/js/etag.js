@@ -0,0 +1,9 @@
+// Форма редактирования получает задачу вместе с полем version и отправляет
+// её обратно целиком. Версия уходит в заголовке If-Match, чтобы сервер
+// отклонил правку (412), если задачу успели изменить.
+axios.interceptors.request.use(function (config) {
+    if (config.method === "put" && config.data && config.data.version) {
+        config.headers["If-Match"] = '"' + config.data.version + '"';
+    }
+    return config;
+});
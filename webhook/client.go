@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress - подписчик во внутренней сети. Без этой проверки
+// подписка позволяла бы обращаться от имени сервера к его окружению.
+var ErrForbiddenAddress = errors.New("адрес во внутренней сети запрещён")
+
+// ForbiddenIP сообщает, что адрес локальный, внутренний или служебный.
+func ForbiddenIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+}
+
+// NewClient возвращает клиент для доставки событий. Если allowPrivate
+// выключен, адрес проверяется при установке соединения, уже после
+// разрешения имени: так не пройдут ни имена, указывающие на внутренние
+// адреса, ни перенаправления на них.
+func NewClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || ForbiddenIP(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		}
+		// Через прокси проверялся бы адрес прокси, а не подписчика.
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+var defaultClient = NewClient(false)
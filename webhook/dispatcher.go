@@ -0,0 +1,191 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go_final_project/db"
+	"go_final_project/metrics"
+)
+
+// Dispatcher отправляет события из очереди webhook_deliveries. Неудачная
+// доставка повторяется с задержкой RetryDelay, 2*RetryDelay, 4*RetryDelay...
+// (не больше MaxDelay), после MaxAttempts попыток помечается failed
+// (0 - повторять без ограничения).
+type Dispatcher struct {
+	DB *db.DB
+	// Client - клиент для доставки; по умолчанию NewClient(false).
+	Client      *http.Client
+	MaxAttempts int
+	RetryDelay  time.Duration
+	MaxDelay    time.Duration
+	// Now подменяется в тестах; по умолчанию time.Now.
+	Now func() time.Time
+}
+
+type delivery struct {
+	id       int64
+	event    string
+	payload  string
+	attempts int
+	url      string
+	secret   string
+}
+
+func (d *Dispatcher) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
+
+// backoff возвращает задержку перед попыткой номер attempts+1.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.RetryDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if d.MaxDelay > 0 && delay >= d.MaxDelay {
+			return d.MaxDelay
+		}
+	}
+	return delay
+}
+
+func (d *Dispatcher) due(ctx context.Context, now string) ([]delivery, error) {
+	start := time.Now()
+	rows, err := d.DB.QueryContext(ctx, `SELECT d.id, d.event, d.payload, d.attempts, w.url, w.secret
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= ?
+		ORDER BY d.id LIMIT 100`, now)
+	metrics.QueryDuration.Since(start, "select_webhook_deliveries")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []delivery
+	for rows.Next() {
+		var dl delivery
+		if err := rows.Scan(&dl.id, &dl.event, &dl.payload, &dl.attempts, &dl.url, &dl.secret); err != nil {
+			return nil, err
+		}
+		due = append(due, dl)
+	}
+	return due, rows.Err()
+}
+
+// send возвращает код ответа подписчика и ошибку, если доставка не удалась.
+func (d *Dispatcher) send(ctx context.Context, dl delivery) (int, error) {
+	body := []byte(dl.payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", dl.event)
+	req.Header.Set("X-Webhook-Delivery", fmt.Sprint(dl.id))
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", Sign(dl.secret, timestamp, body))
+
+	client := d.Client
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("ответ %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// deliveryError - текст ошибки для журнала доставки, который виден через
+// API. Подробности сетевых ошибок пишутся только в журнал сервера: по ним
+// можно было бы изучать сеть, в которой он работает.
+func deliveryError(code int, err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrForbiddenAddress):
+		return ErrForbiddenAddress.Error()
+	case code != 0:
+		return fmt.Sprintf("ответ %d", code)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "превышено время ожидания"
+	default:
+		return "не удалось отправить запрос"
+	}
+}
+
+// Deliver отправляет события, время которых подошло, и возвращает,
+// сколько из них доставлено.
+func (d *Dispatcher) Deliver(ctx context.Context) (int, error) {
+	due, err := d.due(ctx, d.now().UTC().Format(TimeFormat))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при чтении очереди вебхуков: %w", err)
+	}
+
+	delivered := 0
+	for _, dl := range due {
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+		code, sendErr := d.send(ctx, dl)
+		now := d.now()
+		attempts := dl.attempts + 1
+
+		status, lastError, next := "delivered", "", now
+		if sendErr != nil {
+			status, lastError = "pending", deliveryError(code, sendErr)
+			next = now.Add(d.backoff(attempts))
+			if d.MaxAttempts > 0 && attempts >= d.MaxAttempts {
+				status = "failed"
+			}
+		}
+		metrics.WebhookDeliveries.Inc(status)
+
+		_, err := d.DB.ExecContext(ctx, `UPDATE webhook_deliveries
+			SET status = ?, attempts = ?, response_code = ?, last_error = ?, next_attempt_at = ?, updated_at = ?
+			WHERE id = ?`,
+			status, attempts, code, lastError, next.UTC().Format(TimeFormat), now.UTC().Format(TimeFormat), dl.id)
+		if err != nil {
+			return delivered, fmt.Errorf("ошибка при сохранении результата доставки: %w", err)
+		}
+		if sendErr != nil {
+			log.Printf("Ошибка при доставке события %s на %s (попытка %d): %v", dl.event, dl.url, attempts, sendErr)
+			continue
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+// Run проверяет очередь каждые interval и сразу после Wake.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.Deliver(ctx); err != nil && ctx.Err() == nil {
+			log.Println("Ошибка при доставке вебхуков: ", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
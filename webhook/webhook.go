@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go_final_project/db"
+	"go_final_project/metrics"
+)
+
+// События задач, на которые можно подписаться.
+const (
+	TaskCreated = "task.created"
+	TaskUpdated = "task.updated"
+	TaskDeleted = "task.deleted"
+	TaskDone    = "task.done"
+)
+
+var Events = []string{TaskCreated, TaskUpdated, TaskDeleted, TaskDone}
+
+const TimeFormat = "2006-01-02T15:04:05Z"
+
+func ValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload - тело запроса, которое получает подписчик.
+type Payload struct {
+	Event     string      `json:"event"`
+	CreatedAt string      `json:"created_at"`
+	Task      interface{} `json:"task"`
+}
+
+// Sign возвращает значение заголовка X-Webhook-Signature: HMAC-SHA256
+// строки "timestamp.body" на секрете подписки, где timestamp - значение
+// заголовка X-Webhook-Timestamp (секунды Unix). Подписчик проверяет и
+// подпись, и возраст timestamp, чтобы перехваченный запрос нельзя было
+// отправить повторно.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// subscribed проверяет список событий подписки; пустой список - все события.
+func subscribed(events, event string) bool {
+	if events == "" {
+		return true
+	}
+	for _, e := range strings.Split(events, ",") {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Enqueue ставит событие в очередь доставки всем подписчикам. Вызывается
+// в транзакции изменения задачи: если она откатится, событие не уйдёт.
+// После фиксации транзакции нужно вызвать Wake.
+func Enqueue(q db.Queryer, event string, task interface{}) error {
+	start := time.Now()
+	rows, err := q.Query(`SELECT id, events FROM webhooks ORDER BY id`)
+	metrics.QueryDuration.Since(start, "select_webhooks")
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении подписок: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var events string
+		if err := rows.Scan(&id, &events); err != nil {
+			rows.Close()
+			return fmt.Errorf("ошибка при чтении подписок: %w", err)
+		}
+		if subscribed(events, event) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка при чтении подписок: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC().Format(TimeFormat)
+	payload, err := json.Marshal(Payload{Event: event, CreatedAt: now, Task: task})
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		_, err := q.Exec(`INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?)`, id, event, string(payload), now, now, now)
+		if err != nil {
+			return fmt.Errorf("ошибка при постановке события в очередь: %w", err)
+		}
+	}
+	return nil
+}
+
+var wake = make(chan struct{}, 1)
+
+// Wake будит Dispatcher, чтобы новые события ушли сразу, не дожидаясь
+// следующей проверки очереди.
+func Wake() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}